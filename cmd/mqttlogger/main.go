@@ -4,11 +4,17 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/khorsmann/mqttlogger/internal/cli"
 	"github.com/khorsmann/mqttlogger/internal/config"
 	"github.com/khorsmann/mqttlogger/internal/db"
+	"github.com/khorsmann/mqttlogger/internal/health"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
 	"github.com/khorsmann/mqttlogger/internal/mqtt"
+	"github.com/khorsmann/mqttlogger/internal/web"
 )
 
 func printHelp() {
@@ -16,6 +22,17 @@ func printHelp() {
 	fmt.Print(`
   mqttlogger backup <backup-filename>    - erstellt ein Backup
   mqttlogger restore <backup-filename>   - stellt eine DB wieder her
+  mqttlogger health                      - Health-Check (Nagios Exit-Codes)
+  mqttlogger tariff <preis-pro-kwh>      - setzt den Tarif und berechnet Kosten neu
+  mqttlogger devices                     - listet das Geräteregister
+  mqttlogger devices set <quelle>/<id> name=.. room=.. category=.. nominal_power=..
+                                         - benennt und ordnet ein Gerät ein
+  mqttlogger coverage [tage]             - Lücken und Vollständigkeit pro Gerät und Tag
+  mqttlogger quarantine                  - listet abgelehnte Messwerte
+  mqttlogger quarantine accept|discard <id>|all
+                                         - übernimmt bzw. löscht abgelehnte Messwerte
+  mqttlogger dedup [--apply]             - zählt doppelte Messwerte; --apply löscht sie (vorher Backup!)
+  mqttlogger report [monate]             - Vergleich mit Vormonat und Vorjahr, 12-Monats-Summe
   --verbose                   - zeigt Details während der Ausführung
   --debug                     - SQL-Kommandos anzeigen
   --help                      - diese Hilfe
@@ -35,9 +52,37 @@ func main() {
 
 	cfg, err := config.Load("config.toml")
 	if err != nil {
+		if len(os.Args) > 1 && os.Args[1] == "health" {
+			fmt.Println("UNKNOWN - Config: " + err.Error())
+			os.Exit(health.Unknown.ExitCode())
+		}
 		log.Fatalf("Fehler beim Laden der Config: %v", err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "health" {
+		os.Exit(runHealth(cfg, verbose))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "devices" {
+		os.Exit(runDevices(cfg, os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "coverage" {
+		os.Exit(runCoverage(cfg, os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "quarantine" {
+		os.Exit(runQuarantine(cfg, os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "dedup" {
+		os.Exit(runDedup(cfg, os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		os.Exit(runReport(cfg, os.Args[2:]))
+	}
+
 	// CLI-Befehle
 	if len(os.Args) > 2 {
 		command := os.Args[1]
@@ -69,6 +114,32 @@ func main() {
 
 			cli.Success("Restore erfolgreich. Bitte Dienst neu starten!")
 			os.Exit(0)
+
+		case "tariff":
+			perKWh, err := strconv.ParseFloat(path, 64)
+			if err != nil {
+				cli.Error("Ungültiger Tarif: " + path)
+				os.Exit(1)
+			}
+			dbh, err := db.Open(cfg.Database.Path)
+			if err != nil {
+				cli.Error("Konnte DB nicht öffnen.")
+				os.Exit(1)
+			}
+			defer dbh.Close()
+
+			if err := db.CreateSchema(dbh); err != nil {
+				cli.Error("Schema fehlgeschlagen: " + err.Error())
+				os.Exit(1)
+			}
+			if err := db.SetTariff(dbh, perKWh); err != nil {
+				cli.Error("Tarif fehlgeschlagen: " + err.Error())
+				os.Exit(1)
+			}
+			db.RunAggregations(dbh, cfg)
+
+			cli.Success(fmt.Sprintf("Tarif gesetzt: %.4f €/kWh", perKWh))
+			os.Exit(0)
 		}
 	}
 
@@ -82,11 +153,389 @@ func main() {
 	if err := db.InitDB(database, cfg); err != nil {
 		log.Fatalf("Fehler beim Initialisieren der DB: %v", err)
 	}
+	health.Default.Seed(database, cfg)
+
+	metrics.Default.NewGaugeFunc(
+		"mqttlogger_db_size_bytes",
+		"Größe der SQLite-DB inkl. WAL in Bytes.",
+		func() float64 { return float64(db.FileSize(cfg.Database.Path)) },
+	)
 
-	mqtt.StartClient(cfg, database)
+	client := mqtt.StartClient(cfg, database)
+	db.AddAggregationHook(func() {
+		mqtt.PublishAggregates(client, database, cfg)
+		mqtt.PublishDiscovery(client, database, cfg, false)
+	})
+	mqtt.PublishAggregates(client, database, cfg)
+
+	web.Start(cfg, database)
 	select {}
 }
 
+// runHealth fragt den laufenden Dienst ab (falls [http] listen gesetzt ist)
+// oder prüft lokal Broker, DB und Datenaktualität
+func runHealth(cfg config.Config, verbose bool) int {
+	var report health.Report
+
+	if cfg.HTTP.Listen != "" {
+		r, err := health.Fetch(health.LocalURL(cfg.HTTP.Listen, "/readyz"), 5*time.Second)
+		if err != nil {
+			fmt.Println("CRITICAL - Dienst nicht erreichbar: " + err.Error())
+			return health.Critical.ExitCode()
+		}
+		report = r
+	} else {
+		dbh, err := db.Open(cfg.Database.Path)
+		if err != nil {
+			fmt.Println("CRITICAL - DB: " + err.Error())
+			return health.Critical.ExitCode()
+		}
+		defer dbh.Close()
+
+		checker := &health.Checker{
+			Cfg: cfg,
+			DB:  dbh,
+			Connected: func(b config.BrokerConfig) (bool, error) {
+				return true, mqtt.Probe(b, 5*time.Second)
+			},
+			LastSeen: func(source string) (time.Time, bool) {
+				return health.LastSeenInDB(dbh, source)
+			},
+			Now: time.Now,
+		}
+		report = checker.Readiness()
+	}
+
+	fmt.Println(report.Summary())
+	if verbose {
+		for _, c := range report.Checks {
+			fmt.Printf("  %-20s %-8s %s\n", c.Name, c.Status, c.Message)
+		}
+	}
+	return report.Status.ExitCode()
+}
+
+// runDevices listet das Geräteregister oder ändert einen Eintrag
+func runDevices(cfg config.Config, args []string) int {
+	dbh, err := db.Open(cfg.Database.Path)
+	if err != nil {
+		cli.Error("Konnte DB nicht öffnen.")
+		return 1
+	}
+	defer dbh.Close()
+
+	if err := db.CreateSchema(dbh); err != nil {
+		cli.Error("Schema fehlgeschlagen: " + err.Error())
+		return 1
+	}
+
+	if len(args) > 0 && args[0] == "set" {
+		if len(args) < 3 {
+			cli.Error("Aufruf: mqttlogger devices set <quelle>/<id> name=...")
+			return 1
+		}
+		source, id, ok := strings.Cut(args[1], "/")
+		if !ok || source == "" || id == "" {
+			cli.Error("Ungültiges Gerät: " + args[1])
+			return 1
+		}
+		u, err := parseDeviceUpdate(args[2:])
+		if err != nil {
+			cli.Error(err.Error())
+			return 1
+		}
+		if _, err := db.UpdateDevice(dbh, source, id, u); err != nil {
+			cli.Error("Gerät ändern fehlgeschlagen: " + err.Error())
+			return 1
+		}
+		cli.Success("Gerät gespeichert: " + args[1])
+		return 0
+	}
+
+	devices, err := db.Devices(dbh)
+	if err != nil {
+		cli.Error("Geräte lesen fehlgeschlagen: " + err.Error())
+		return 1
+	}
+	for _, d := range devices {
+		power := ""
+		if d.NominalPower != nil {
+			power = fmt.Sprintf("%.0f W", *d.NominalPower)
+		}
+		lastSeen := ""
+		if d.LastSeen != nil {
+			lastSeen = d.LastSeen.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-10s %-24s %-20s %-12s %-12s %8s  %s\n",
+			d.Source, d.ID, d.Name, d.Room, d.Category, power, lastSeen)
+	}
+	return 0
+}
+
+// runCoverage zeigt die Vollständigkeit der letzten Tage (Standard: 7)
+func runCoverage(cfg config.Config, args []string) int {
+	days := 7
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			cli.Error("Ungültige Anzahl Tage: " + args[0])
+			return 1
+		}
+		days = n
+	}
+
+	dbh, err := db.Open(cfg.Database.Path)
+	if err != nil {
+		cli.Error("Konnte DB nicht öffnen.")
+		return 1
+	}
+	defer dbh.Close()
+
+	if err := db.CreateSchema(dbh); err != nil {
+		cli.Error("Schema fehlgeschlagen: " + err.Error())
+		return 1
+	}
+
+	from, to := health.CoverageDays(cfg, days, time.Now())
+	report, err := health.Coverage(dbh, cfg, from, to)
+	if err != nil {
+		cli.Error("Auswertung fehlgeschlagen: " + err.Error())
+		return 1
+	}
+	for _, c := range report {
+		fmt.Printf("%-12s %-24s %s %6.1f %%  %6d Werte\n", c.Source, c.Device, c.Day, c.Completeness, c.Readings)
+		for _, g := range c.Gaps {
+			fmt.Printf("    Lücke %s – %s (%s)\n",
+				g.From.Format("15:04:05"), g.To.Format("15:04:05"), time.Duration(g.Seconds)*time.Second)
+		}
+	}
+	return 0
+}
+
+// runQuarantine listet, übernimmt oder verwirft abgelehnte Messwerte
+func runQuarantine(cfg config.Config, args []string) int {
+	dbh, err := db.Open(cfg.Database.Path)
+	if err != nil {
+		cli.Error("Konnte DB nicht öffnen.")
+		return 1
+	}
+	defer dbh.Close()
+
+	if err := db.CreateSchema(dbh); err != nil {
+		cli.Error("Schema fehlgeschlagen: " + err.Error())
+		return 1
+	}
+
+	entries, err := db.QuarantineList(dbh)
+	if err != nil {
+		cli.Error("Quarantäne lesen fehlgeschlagen: " + err.Error())
+		return 1
+	}
+
+	if len(args) == 0 || args[0] == "list" {
+		for _, q := range entries {
+			fmt.Printf("%5d  %-12s %-20s %s  %s\n",
+				q.ID, q.Source, q.Device, q.Time.Local().Format("2006-01-02 15:04:05"), q.Reason)
+		}
+		if len(entries) == 0 {
+			cli.Success("Quarantäne ist leer.")
+		}
+		return 0
+	}
+
+	action := args[0]
+	if (action != "accept" && action != "discard") || len(args) < 2 {
+		cli.Error("Aufruf: mqttlogger quarantine [list | accept <id>|all | discard <id>|all]")
+		return 1
+	}
+	var ids []int64
+	if args[1] == "all" {
+		for _, q := range entries {
+			ids = append(ids, q.ID)
+		}
+	} else {
+		for _, a := range args[1:] {
+			id, err := strconv.ParseInt(a, 10, 64)
+			if err != nil {
+				cli.Error("Ungültige ID: " + a)
+				return 1
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range ids {
+		if action == "accept" {
+			err = db.AcceptQuarantined(dbh, id)
+		} else {
+			err = db.DiscardQuarantined(dbh, id)
+		}
+		if err != nil {
+			cli.Error(fmt.Sprintf("Eintrag %d: %v", id, err))
+			return 1
+		}
+	}
+	if action == "accept" {
+		db.RunAggregations(dbh, cfg)
+		cli.Success(fmt.Sprintf("%d Messwerte übernommen", len(ids)))
+	} else {
+		cli.Success(fmt.Sprintf("%d Messwerte verworfen", len(ids)))
+	}
+	return 0
+}
+
+// runDedup listet doppelte Messwerte aus Altdaten; erst mit --apply werden
+// sie gelöscht und die fehlenden UNIQUE-Indizes angelegt
+func runDedup(cfg config.Config, args []string) int {
+	dbh, err := db.Open(cfg.Database.Path)
+	if err != nil {
+		cli.Error("Konnte DB nicht öffnen.")
+		return 1
+	}
+	defer dbh.Close()
+
+	if err := db.CreateSchema(dbh); err != nil {
+		cli.Error("Schema fehlgeschlagen: " + err.Error())
+		return 1
+	}
+
+	dups, err := db.Duplicates(dbh)
+	if err != nil {
+		cli.Error("Duplikate zählen fehlgeschlagen: " + err.Error())
+		return 1
+	}
+	if len(dups) == 0 {
+		cli.Success("Keine doppelten Messwerte.")
+		return 0
+	}
+	for _, d := range dups {
+		fmt.Printf("%-14s %8d doppelte Zeilen\n", d.Table, d.Rows)
+	}
+
+	if !contains(args, "--apply") {
+		cli.Info("Zum Löschen (erste Zeile je Schlüssel bleibt): vorher Backup anlegen (mqttlogger backup <datei>), dann mqttlogger dedup --apply")
+		return 0
+	}
+	removed, err := db.RemoveDuplicates(dbh)
+	if err != nil {
+		cli.Error("Bereinigung fehlgeschlagen: " + err.Error())
+		return 1
+	}
+	total := 0
+	for _, d := range removed {
+		total += d.Rows
+	}
+	db.RunAggregations(dbh, cfg)
+	cli.Success(fmt.Sprintf("%d doppelte Messwerte gelöscht, Indizes angelegt", total))
+	return 0
+}
+
+// runReport zeigt die letzten Monate und alle Jahre im Vergleich
+// (Stand des letzten Aggregationslaufs)
+func runReport(cfg config.Config, args []string) int {
+	months := 12
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			cli.Error("Ungültige Anzahl Monate: " + args[0])
+			return 1
+		}
+		months = n
+	}
+
+	dbh, err := db.Open(cfg.Database.Path)
+	if err != nil {
+		cli.Error("Konnte DB nicht öffnen.")
+		return 1
+	}
+	defer dbh.Close()
+
+	if err := db.CreateSchema(dbh); err != nil {
+		cli.Error("Schema fehlgeschlagen: " + err.Error())
+		return 1
+	}
+
+	monthly, err := db.MonthComparisons(dbh, months)
+	if err != nil {
+		cli.Error("Auswertung fehlgeschlagen: " + err.Error())
+		return 1
+	}
+	yearly, err := db.YearComparisons(dbh)
+	if err != nil {
+		cli.Error("Auswertung fehlgeschlagen: " + err.Error())
+		return 1
+	}
+
+	cli.Bold("Monate")
+	fmt.Printf("%-8s %10s %9s %10s %8s %10s %8s %11s %9s\n",
+		"Monat", "kWh", "Kosten", "Vormonat", "Δ %", "Vorjahr", "Δ %", "12 Mon kWh", "Kosten")
+	for _, m := range monthly {
+		fmt.Printf("%-8s %10.1f %9.2f %10s %8s %10s %8s %11.1f %9.2f%s\n",
+			m.Month, m.Consumption, m.Cost,
+			optional(m.PrevMonthConsumption, "%.1f"), optional(m.MoMDeltaPct, "%+.1f"),
+			optional(m.LastYearConsumption, "%.1f"), optional(m.YoYDeltaPct, "%+.1f"),
+			m.Rolling12mConsumption, m.Rolling12mCost, incomplete(m.Complete))
+	}
+
+	fmt.Println()
+	cli.Bold("Jahre")
+	fmt.Printf("%-8s %10s %9s %10s %9s %8s\n", "Jahr", "kWh", "Kosten", "Vorjahr", "Kosten", "Δ %")
+	for _, y := range yearly {
+		fmt.Printf("%-8d %10.1f %9.2f %10s %9s %8s%s\n",
+			y.Year, y.Consumption, y.Cost,
+			optional(y.PrevYearConsumption, "%.1f"), optional(y.PrevYearCost, "%.2f"),
+			optional(y.YoYDeltaPct, "%+.1f"), incomplete(y.Complete))
+	}
+	return 0
+}
+
+// optional formatiert v oder "–", wenn kein Vergleichswert existiert
+func optional(v *float64, format string) string {
+	if v == nil {
+		return "–"
+	}
+	return fmt.Sprintf(format, *v)
+}
+
+// incomplete markiert Perioden mit Lücken in energy_data
+func incomplete(complete bool) string {
+	if complete {
+		return ""
+	}
+	return "  (unvollständig)"
+}
+
+// parseDeviceUpdate liest key=value-Paare; leere Werte löschen das Feld
+func parseDeviceUpdate(args []string) (db.DeviceUpdate, error) {
+	var u db.DeviceUpdate
+	for _, a := range args {
+		key, value, ok := strings.Cut(a, "=")
+		if !ok {
+			return u, fmt.Errorf("Ungültige Angabe %q (erwartet key=value)", a)
+		}
+		switch key {
+		case "name":
+			u.Name = &value
+		case "room":
+			u.Room = &value
+		case "category":
+			u.Category = &value
+		case "nominal_power":
+			if value == "" {
+				return u, fmt.Errorf("nominal_power braucht einen Wert")
+			}
+			p, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return u, fmt.Errorf("Ungültige Nennleistung: %s", value)
+			}
+			u.NominalPower = &p
+		default:
+			return u, fmt.Errorf("Unbekanntes Feld %q", key)
+		}
+	}
+	return u, nil
+}
+
 // Helper
 func contains(list []string, val string) bool {
 	for _, v := range list {
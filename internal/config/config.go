@@ -1,20 +1,48 @@
 package config
 
 import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
 	"github.com/BurntSushi/toml"
 )
 
+// BrokerConfig beschreibt eine Broker-Verbindung. Name und Topics werden
+// nur in [[brokers]] ausgewertet.
 type BrokerConfig struct {
+	Name     string `toml:"name"`
 	Host     string `toml:"host"`
 	Username string `toml:"username"`
 	Password string `toml:"password"`
 	ClientID string `toml:"client_id"`
 	Qos      byte   `toml:"qos"`
 	SetDebug bool   `toml:"debug"`
+	// Protocol ist "3.1.1" (Standard) oder "5"
+	Protocol string `toml:"protocol"`
+	// SharedGroup abonniert die Mess-Topics als $share/<group>/<topic>
+	SharedGroup string `toml:"shared_group"`
+	// Headers werden beim WebSocket-Handshake (ws://, wss://) mitgeschickt
+	Headers map[string]string `toml:"headers"`
+	// Proxy für WebSocket-Verbindungen: leer = HTTPS_PROXY/NO_PROXY, "none" = direkt
+	Proxy string `toml:"proxy"`
+
+	TLS    TLSConfig    `toml:"tls"`
+	Topics TopicsConfig `toml:"topics"`
+}
+
+// TLSConfig für mqtts://-Verbindungen; leere Felder nutzen die System-CAs
+type TLSConfig struct {
+	CAFile             string `toml:"ca_file"`
+	CertFile           string `toml:"cert_file"`
+	KeyFile            string `toml:"key_file"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
 }
 
 type DatabaseConfig struct {
-	Path string `toml:"path"`
+	Path      string `toml:"path"`
+	BackupDir string `toml:"backup_dir"`
 }
 
 type CostConfig struct {
@@ -26,27 +54,330 @@ type FeatureFlags struct {
 	SolarEnabled        bool `toml:"solar"`
 }
 
+// TimeConfig steuert, welcher Zeitstempel gespeichert wird. Geräte-Zeiten
+// ohne Zone gelten in Timezone.
 type TimeConfig struct {
-	Timezone    string `toml:"timezone"`
-	InputFormat string `toml:"input_format"`
+	Timezone string `toml:"timezone"`
+	// InputFormat/InputFormats: zusätzliche Go-Layouts, "unix" oder "unix_ms"
+	InputFormat  string   `toml:"input_format"`
+	InputFormats []string `toml:"input_formats"`
+
+	TimePolicy
+	// Sources überschreibt die Richtlinie pro Quelle (wattwaechter, tasmota, ...)
+	Sources map[string]TimePolicy `toml:"sources"`
+}
+
+// TimePolicy: "device" nutzt die Geräte-Zeit, "receive" die Empfangszeit,
+// "device_max_skew" die Geräte-Zeit, solange sie höchstens MaxSkew abweicht.
+// Ab WarnSkew Abweichung wird gewarnt und gezählt.
+type TimePolicy struct {
+	Policy   string        `toml:"policy"`
+	MaxSkew  time.Duration `toml:"max_skew"`
+	WarnSkew time.Duration `toml:"warn_skew"`
+}
+
+const (
+	TimeDevice        = "device"
+	TimeReceive       = "receive"
+	TimeDeviceMaxSkew = "device_max_skew"
+)
+
+// PolicyFor liefert die Richtlinie einer Quelle mit Standardwerten
+func (t TimeConfig) PolicyFor(source string) TimePolicy {
+	p := t.TimePolicy
+	if s, ok := t.Sources[source]; ok {
+		if s.Policy != "" {
+			p.Policy = s.Policy
+		}
+		if s.MaxSkew != 0 {
+			p.MaxSkew = s.MaxSkew
+		}
+		if s.WarnSkew != 0 {
+			p.WarnSkew = s.WarnSkew
+		}
+	}
+	if p.Policy == "" {
+		p.Policy = TimeDevice
+	}
+	if p.MaxSkew == 0 {
+		p.MaxSkew = 5 * time.Minute
+	}
+	if p.WarnSkew == 0 {
+		p.WarnSkew = 2 * time.Minute
+	}
+	return p
+}
+
+// Location liefert die Zone aus Timezone; leer ist UTC, ungültig lokal.
+// Geräte-Zeiten und die Perioden der Aggregate richten sich danach.
+func (t TimeConfig) Location() *time.Location {
+	if loc, err := time.LoadLocation(t.Timezone); err == nil {
+		return loc
+	}
+	return time.Local
 }
 
 type TopicsConfig struct {
-	Wattwaechter string `toml:"wattwaechter"`
-	Tasmota      string `toml:"tasmota"`
+	Wattwaechter string    `toml:"wattwaechter"`
+	Tasmota      TopicList `toml:"tasmota"`
+	// Shelly Gen1 (shellies/#) und Gen2 (<id>/events/rpc, <id>/status/#)
+	Shelly TopicList `toml:"shelly"`
+	// Zigbee2MQTT-Abo inkl. Basis-Topic, z.B. "zigbee2mqtt/#"
+	Zigbee2MQTT string `toml:"zigbee2mqtt"`
+	// Rohe Zählertelegramme (SML binär/Hex oder D0-Text) von IR-Leseköpfen
+	SML TopicList `toml:"sml"`
+	// Solar wird nur in [[brokers]] ausgewertet, sonst gilt [features] solar
+	Solar string `toml:"solar"`
+}
+
+// TopicList ist ein einzelnes Topic oder eine Liste von Topics
+type TopicList []string
+
+// UnmarshalTOML akzeptiert `tasmota = "a"` und `tasmota = ["a", "b"]`
+func (l *TopicList) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		if v != "" {
+			*l = TopicList{v}
+		}
+	case []any:
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return fmt.Errorf("Topic-Liste: %v ist kein String", e)
+			}
+			*l = append(*l, s)
+		}
+	default:
+		return fmt.Errorf("Topic-Liste: unerwarteter Typ %T", v)
+	}
+	return nil
+}
+
+// TasmotaConfig beschreibt Topic-Layout und Geräteauswahl der Tasmota-Geräte
+type TasmotaConfig struct {
+	// FullTopic wie in Tasmota (Standard "%prefix%/%topic%/")
+	FullTopic string   `toml:"full_topic"`
+	Allow     []string `toml:"allow"`
+	Deny      []string `toml:"deny"`
+	// Names benennt Geräte um (Topic-Name -> gespeicherte Geräte-ID)
+	Names map[string]string `toml:"names"`
+}
+
+// WattwaechterConfig ordnet abweichende JSON-Feldnamen den gespeicherten
+// Größen zu, z.B. Leistung_L1 = "power_l1"
+type WattwaechterConfig struct {
+	Fields map[string]string `toml:"fields"`
+}
+
+// DedupConfig steuert doppelte Messwerte (gleiche Quelle, Gerät und
+// Zeitstempel) und retained Nachrichten
+type DedupConfig struct {
+	// OnConflict: ignore (Standard), replace, merge oder error
+	OnConflict       string            `toml:"on_conflict"`
+	OnConflictSource map[string]string `toml:"on_conflict_source"`
+	// SkipRetained verwirft retained Messwerte (z.B. nach Reconnect)
+	SkipRetained bool `toml:"skip_retained"`
+}
+
+const (
+	ConflictIgnore  = "ignore"
+	ConflictReplace = "replace"
+	ConflictMerge   = "merge"
+	ConflictError   = "error"
+)
+
+// OnConflictFor liefert die Konfliktbehandlung einer Quelle. Shelly Gen1
+// schickt jeden Wert auf einem eigenen Topic und führt daher standardmäßig
+// zusammen.
+func (d DedupConfig) OnConflictFor(source string) string {
+	if c, ok := d.OnConflictSource[source]; ok {
+		return c
+	}
+	if source == "shelly" {
+		return ConflictMerge
+	}
+	if d.OnConflict == "" {
+		return ConflictIgnore
+	}
+	return d.OnConflict
+}
+
+// ValidationConfig enthält Prüfregeln für eingehende Messwerte. Schlüssel
+// ist "<quelle>/<metrik>" (alle Geräte) oder "<quelle>/<gerät>/<metrik>",
+// z.B. "wattwaechter/e_in" oder "tasmota/plug1/energy_total".
+type ValidationConfig struct {
+	Rules map[string]ValidationRule `toml:"rules"`
+}
+
+// ValidationRule: Min/Max begrenzen den Wert, MaxRate die Änderung pro
+// Stunde, Monotonic verbietet fallende Zählerstände
+type ValidationRule struct {
+	Min       *float64 `toml:"min"`
+	Max       *float64 `toml:"max"`
+	MaxRate   float64  `toml:"max_rate"`
+	Monotonic bool     `toml:"monotonic"`
+}
+
+// RuleFor liefert die Regel einer Reihe; gerätespezifische Regeln haben Vorrang
+func (v ValidationConfig) RuleFor(source, device, metric string) (ValidationRule, bool) {
+	if r, ok := v.Rules[source+"/"+device+"/"+metric]; ok {
+		return r, true
+	}
+	r, ok := v.Rules[source+"/"+metric]
+	return r, ok
+}
+
+type HTTPConfig struct {
+	Listen string         `toml:"listen"`
+	Auth   HTTPAuthConfig `toml:"auth"`
+}
+
+// Scope "read" erlaubt Abfragen, "admin" zusätzlich Backups,
+// Re-Aggregation und Tarifänderungen
+type HTTPToken struct {
+	Token string `toml:"token"`
+	Scope string `toml:"scope"`
+}
+
+type HTTPUser struct {
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	Scope    string `toml:"scope"`
+}
+
+type HTTPAuthConfig struct {
+	Tokens []HTTPToken `toml:"tokens"`
+	Users  []HTTPUser  `toml:"users"`
+}
+
+type HealthConfig struct {
+	StaleAfter       time.Duration            `toml:"stale_after"`
+	StaleAfterSource map[string]time.Duration `toml:"stale_after_source"`
+
+	// ExpectedInterval: längere Pausen zwischen zwei Messwerten gelten als Lücke
+	ExpectedInterval       time.Duration            `toml:"expected_interval"`
+	ExpectedIntervalSource map[string]time.Duration `toml:"expected_interval_source"`
+}
+
+// DefaultExpectedInterval deckt die Tasmota-TelePeriod (300 s) mit Reserve ab
+const DefaultExpectedInterval = 6 * time.Minute
+
+// ExpectedIntervalFor liefert das erwartete Messintervall einer Quelle
+func (h HealthConfig) ExpectedIntervalFor(source string) time.Duration {
+	if d, ok := h.ExpectedIntervalSource[source]; ok && d > 0 {
+		return d
+	}
+	if h.ExpectedInterval > 0 {
+		return h.ExpectedInterval
+	}
+	return DefaultExpectedInterval
+}
+
+// Veröffentlichung der Aggregate als retained JSON-Nachrichten.
+// Leere Topics werden aus topic_prefix abgeleitet.
+type PublishConfig struct {
+	Enabled     bool   `toml:"enabled"`
+	TopicPrefix string `toml:"topic_prefix"`
+	Today       string `toml:"today"`
+	Month       string `toml:"month"`
+	Year        string `toml:"year"`
+	Tariff      string `toml:"tariff"`
+	Qos         byte   `toml:"qos"`
+}
+
+// Online-Status (Last Will) und Heartbeat des Loggers.
+// Leere Topics werden aus [publish] topic_prefix abgeleitet.
+type StatusConfig struct {
+	Enabled           bool          `toml:"enabled"`
+	Topic             string        `toml:"topic"`
+	PayloadOnline     string        `toml:"payload_online"`
+	PayloadOffline    string        `toml:"payload_offline"`
+	HeartbeatTopic    string        `toml:"heartbeat_topic"`
+	HeartbeatInterval time.Duration `toml:"heartbeat_interval"`
+}
+
+// Home Assistant MQTT-Discovery für die abgeleiteten Sensoren
+type HomeAssistantConfig struct {
+	Enabled         bool   `toml:"enabled"`
+	DiscoveryPrefix string `toml:"discovery_prefix"`
+	NodeID          string `toml:"node_id"`
 }
 
 type Config struct {
 	Broker   BrokerConfig   `toml:"broker"`
+	Brokers  []BrokerConfig `toml:"brokers"`
 	Database DatabaseConfig `toml:"database"`
 	Time     TimeConfig     `toml:"time"`
+	Dedup    DedupConfig    `toml:"dedup"`
 	Topics   TopicsConfig   `toml:"topics"`
 	Features FeatureFlags   `toml:"features"`
+	Tasmota  TasmotaConfig  `toml:"tasmota"`
 	Cost     CostConfig     `toml:"cost"`
+	HTTP     HTTPConfig     `toml:"http"`
+	Health   HealthConfig   `toml:"health"`
+	Publish  PublishConfig  `toml:"publish"`
+	Status   StatusConfig   `toml:"status"`
+
+	HomeAssistant HomeAssistantConfig `toml:"homeassistant"`
+	Wattwaechter  WattwaechterConfig  `toml:"wattwaechter"`
+	Validation    ValidationConfig    `toml:"validation"`
 }
 
 func Load(path string) (Config, error) {
 	var cfg Config
-	_, err := toml.DecodeFile(path, &cfg)
-	return cfg, err
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		return cfg, err
+	}
+
+	for _, p := range slices.AppendSeq([]TimePolicy{cfg.Time.TimePolicy}, maps.Values(cfg.Time.Sources)) {
+		switch p.Policy {
+		case "", TimeDevice, TimeReceive, TimeDeviceMaxSkew:
+		default:
+			return cfg, fmt.Errorf("unbekannte Zeit-Richtlinie %q (device, receive, device_max_skew)", p.Policy)
+		}
+	}
+
+	for _, c := range slices.AppendSeq([]string{cfg.Dedup.OnConflict}, maps.Values(cfg.Dedup.OnConflictSource)) {
+		switch c {
+		case "", ConflictIgnore, ConflictReplace, ConflictMerge, ConflictError:
+		default:
+			return cfg, fmt.Errorf("unbekanntes on_conflict %q (ignore, replace, merge, error)", c)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, b := range cfg.BrokerList() {
+		if seen[b.Name] {
+			return cfg, fmt.Errorf("Broker-Name %q mehrfach vergeben", b.Name)
+		}
+		seen[b.Name] = true
+	}
+	return cfg, nil
+}
+
+// BrokerList liefert alle Broker-Verbindungen mit ihren Topics. Ohne
+// [[brokers]] gilt die Einzelkonfiguration aus [broker] und [topics].
+func (c Config) BrokerList() []BrokerConfig {
+	if len(c.Brokers) == 0 {
+		b := c.Broker
+		b.Topics = c.Topics
+		b.Topics.Solar = ""
+		if c.Features.SolarEnabled {
+			b.Topics.Solar = "solar/#"
+		}
+		if b.Name == "" {
+			b.Name = "default"
+		}
+		return []BrokerConfig{b}
+	}
+
+	out := make([]BrokerConfig, 0, len(c.Brokers))
+	for i, b := range c.Brokers {
+		if b.Name == "" {
+			b.Name = fmt.Sprintf("broker%d", i+1)
+		}
+		out = append(out, b)
+	}
+	return out
 }
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestTasmotaTopicsAsStringOrList(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+[topics]
+tasmota = "tele/+/SENSOR"
+`))
+	if err != nil || !slices.Equal(cfg.Topics.Tasmota, []string{"tele/+/SENSOR"}) {
+		t.Fatalf("string: %v %v", cfg.Topics.Tasmota, err)
+	}
+
+	cfg, err = Load(writeConfig(t, `
+[topics]
+tasmota = ["tele/+/SENSOR", "haus/+/tele/SENSOR"]
+`))
+	if err != nil || len(cfg.Topics.Tasmota) != 2 {
+		t.Fatalf("list: %v %v", cfg.Topics.Tasmota, err)
+	}
+
+	if _, err := Load(writeConfig(t, "[topics]\ntasmota = 5\n")); err == nil {
+		t.Fatal("expected error for number")
+	}
+}
+
+func TestBrokerListNamesAndDuplicates(t *testing.T) {
+	cfg := Config{Broker: BrokerConfig{Host: "tcp://a:1883"}, Features: FeatureFlags{SolarEnabled: true}}
+	if l := cfg.BrokerList(); len(l) != 1 || l[0].Name != "default" || l[0].Topics.Solar != "solar/#" {
+		t.Fatalf("legacy broker: %+v", l)
+	}
+
+	if _, err := Load(writeConfig(t, `
+[[brokers]]
+name = "a"
+[[brokers]]
+name = "a"
+`)); err == nil {
+		t.Fatal("expected error for duplicate broker names")
+	}
+}
+
+func TestTimePolicies(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+[time]
+policy = "device_max_skew"
+max_skew = "10m"
+
+[time.sources.solar]
+policy = "receive"
+
+[time.sources.tasmota]
+warn_skew = "30s"
+`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if p := cfg.Time.PolicyFor("solar"); p.Policy != TimeReceive || p.MaxSkew != 10*time.Minute {
+		t.Fatalf("solar: %+v", p)
+	}
+	if p := cfg.Time.PolicyFor("tasmota"); p.Policy != TimeDeviceMaxSkew || p.WarnSkew != 30*time.Second {
+		t.Fatalf("tasmota: %+v", p)
+	}
+	if p := (TimeConfig{}).PolicyFor("shelly"); p.Policy != TimeDevice {
+		t.Fatalf("default: %+v", p)
+	}
+
+	if _, err := Load(writeConfig(t, "[time.sources.zigbee]\npolicy = \"geraet\"\n")); err == nil {
+		t.Fatal("expected error for unknown policy")
+	}
+}
+
+func TestDedupOnConflict(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+[dedup]
+on_conflict = "replace"
+on_conflict_source = { zigbee = "error" }
+`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for source, want := range map[string]string{"tasmota": ConflictReplace, "zigbee": ConflictError, "shelly": ConflictMerge} {
+		if got := cfg.Dedup.OnConflictFor(source); got != want {
+			t.Errorf("%s: %q, want %q", source, got, want)
+		}
+	}
+	if got := (DedupConfig{}).OnConflictFor("tasmota"); got != ConflictIgnore {
+		t.Errorf("default: %q", got)
+	}
+	if _, err := Load(writeConfig(t, "[dedup]\non_conflict = \"drop\"\n")); err == nil {
+		t.Fatal("expected error for unknown on_conflict")
+	}
+}
+
+func TestValidationRules(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+[validation.rules."wattwaechter/e_in"]
+min = 0.0
+max_rate = 20
+monotonic = true
+
+[validation.rules."tasmota/heater/power"]
+max = 3000.0
+`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	r, ok := cfg.Validation.RuleFor("wattwaechter", "", "e_in")
+	if !ok || r.Min == nil || *r.Min != 0 || r.Max != nil || r.MaxRate != 20 || !r.Monotonic {
+		t.Fatalf("e_in: %+v %v", r, ok)
+	}
+	if r, ok := cfg.Validation.RuleFor("tasmota", "heater", "power"); !ok || *r.Max != 3000 {
+		t.Fatalf("heater: %+v %v", r, ok)
+	}
+	if _, ok := cfg.Validation.RuleFor("tasmota", "plug", "power"); ok {
+		t.Fatal("rule for other device")
+	}
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// -------------------------------------------------------------------
+// Zählerstand an Periodengrenzen (linear interpoliert)
+// -------------------------------------------------------------------
+
+// counterPoint ist ein Zählerstand e_in zum Zeitpunkt ts (Unix)
+type counterPoint struct {
+	ts int64
+	v  float64
+}
+
+// counter sind die Stützstellen von e_in, aufsteigend nach Zeit
+type counter []counterPoint
+
+// counterBucket: erster und letzter Wert je 15 Minuten reichen für exakte
+// Interpolation, solange Periodengrenzen auf vollen Viertelstunden liegen
+// (gilt für alle Zeitzonen)
+const counterBucket = 900
+
+// loadCounter liest die Stützstellen aus energy_data
+func loadCounter(db *sql.DB) (counter, error) {
+	// SQLite liefert bei MIN()/MAX() die übrigen Spalten aus derselben Zeile
+	rows, err := db.Query(`
+		SELECT MIN(timestamp_unix), e_in FROM energy_data
+		WHERE timestamp_unix > 0 AND e_in IS NOT NULL
+		GROUP BY timestamp_unix / ?
+		UNION
+		SELECT MAX(timestamp_unix), e_in FROM energy_data
+		WHERE timestamp_unix > 0 AND e_in IS NOT NULL
+		GROUP BY timestamp_unix / ?
+		ORDER BY 1
+	`, counterBucket, counterBucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var c counter
+	for rows.Next() {
+		var p counterPoint
+		if err := rows.Scan(&p.ts, &p.v); err != nil {
+			return nil, err
+		}
+		c = append(c, p)
+	}
+	return c, rows.Err()
+}
+
+// at liefert den Zählerstand zum Zeitpunkt t. Vor dem ersten und nach dem
+// letzten Wert bleibt der Zähler stehen.
+func (c counter) at(t time.Time) float64 {
+	ts := t.Unix()
+	i := sort.Search(len(c), func(i int) bool { return c[i].ts >= ts })
+	switch {
+	case i == 0:
+		return c[0].v
+	case i == len(c):
+		return c[len(c)-1].v
+	case c[i].ts == ts:
+		return c[i].v
+	}
+	a, b := c[i-1], c[i]
+	return a.v + (b.v-a.v)*float64(ts-a.ts)/float64(b.ts-a.ts)
+}
+
+// periodTotal ist der Verbrauch einer Periode; start ist der erste Tag mit
+// Daten (00:00 in loc)
+type periodTotal struct {
+	key         string
+	start       time.Time
+	consumption float64
+}
+
+// periodTotals bildet Perioden aus aufeinanderfolgenden Tagen mit gleichem
+// Schlüssel und rechnet Zählerstand(Ende) − Zählerstand(Anfang). Damit
+// ergeben die Tage in Summe genau Monat und Jahr.
+func (c counter) periodTotals(loc *time.Location, key func(time.Time) string) []periodTotal {
+	if len(c) == 0 {
+		return nil
+	}
+	first := time.Unix(c[0].ts, 0).In(loc)
+	last := time.Unix(c[len(c)-1].ts, 0).In(loc)
+
+	var out []periodTotal
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+	start := day
+	for !day.After(last) {
+		next := day.AddDate(0, 0, 1)
+		k := key(day)
+		if next.After(last) || key(next) != k {
+			v := c.at(next) - c.at(start)
+			if v < 0 {
+				// Zählerwechsel o.ä.
+				v = 0
+			}
+			out = append(out, periodTotal{k, start, v})
+			start = next
+		}
+		day = next
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPeriodTotalsAddUp(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	// alle 7 Minuten über einen Monatswechsel, mit zwei Tagen Ausfall
+	start := time.Date(2025, 1, 20, 3, 17, 0, 0, time.UTC)
+	e := 1000.0
+	var first, last float64
+	for ts, i := start, 0; ts.Before(start.AddDate(0, 0, 20)); ts, i = ts.Add(7*time.Minute), i+1 {
+		if ts.Day() == 2 || ts.Day() == 3 {
+			continue
+		}
+		e += 0.05 + float64(i%5)*0.01
+		if first == 0 {
+			first = e
+		}
+		last = e
+		if _, err := dbh.Exec(`INSERT INTO energy_data (timestamp_unix, e_in) VALUES (?, ?)`, ts.Unix(), e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	c, err := loadCounter(dbh)
+	if err != nil {
+		t.Fatalf("loadCounter: %v", err)
+	}
+	sum := func(totals []periodTotal) (s float64) {
+		for _, p := range totals {
+			s += p.consumption
+		}
+		return s
+	}
+	days := c.periodTotals(time.UTC, dayKey)
+	months := c.periodTotals(time.UTC, monthKey)
+	if len(days) != 21 || len(months) != 2 {
+		t.Fatalf("days = %d, months = %d", len(days), len(months))
+	}
+	for name, got := range map[string]float64{
+		"days": sum(days), "weeks": sum(c.periodTotals(time.UTC, isoWeek)),
+		"months": sum(months), "years": sum(c.periodTotals(time.UTC, yearKey)),
+	} {
+		if math.Abs(got-(last-first)) > 1e-9 {
+			t.Errorf("%s sum = %v, want %v", name, got, last-first)
+		}
+	}
+	if math.Abs(months[0].consumption+months[1].consumption-sum(days)) > 1e-9 {
+		t.Errorf("months %v do not match days", months)
+	}
+
+	// Ausfalltage bekommen den interpolierten Anteil
+	for _, d := range days {
+		if d.key == "2025-02-02" && d.consumption <= 0 {
+			t.Errorf("gap day = %+v", d)
+		}
+	}
+}
+
+func TestCounterInterpolation(t *testing.T) {
+	c := counter{{ts: 100, v: 10}, {ts: 200, v: 20}, {ts: 400, v: 20}}
+	for _, tt := range []struct {
+		ts   int64
+		want float64
+	}{{50, 10}, {100, 10}, {150, 15}, {200, 20}, {300, 20}, {500, 20}} {
+		if got := c.at(time.Unix(tt.ts, 0)); got != tt.want {
+			t.Errorf("at(%d) = %v, want %v", tt.ts, got, tt.want)
+		}
+	}
+}
+
+func TestISOWeek(t *testing.T) {
+	for _, tt := range []struct {
+		day  time.Time
+		want string
+	}{
+		{time.Date(2024, 12, 29, 12, 0, 0, 0, time.UTC), "2024-W52"},
+		{time.Date(2024, 12, 30, 12, 0, 0, 0, time.UTC), "2025-W01"},
+		{time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), "2025-W01"},
+		{time.Date(2020, 12, 31, 12, 0, 0, 0, time.UTC), "2020-W53"},
+		{time.Date(2021, 1, 3, 12, 0, 0, 0, time.UTC), "2020-W53"},
+		{time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC), "2021-W01"},
+		{time.Date(2026, 12, 31, 12, 0, 0, 0, time.UTC), "2026-W53"},
+	} {
+		if got := isoWeek(tt.day); got != tt.want {
+			t.Errorf("isoWeek(%s) = %s, want %s", tt.day.Format("2006-01-02"), got, tt.want)
+		}
+	}
+	if got := weekStart(time.Date(2021, 1, 3, 23, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("weekStart = %v", got)
+	}
+}
+
+func TestAggregateWeeklyAcrossYearEnd(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata: %v", err)
+	}
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	// stündlich 1 kWh von So 27.12.2020 bis Mo 11.01.2021, 00:00 in Berlin
+	start := time.Date(2020, 12, 27, 0, 0, 0, 0, berlin)
+	for i := 0; i <= 15*24; i++ {
+		ts := start.Add(time.Duration(i) * time.Hour)
+		if _, err := dbh.Exec(`INSERT INTO energy_data (timestamp_unix, e_in) VALUES (?, ?)`, ts.Unix(), float64(i)); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	c, err := loadCounter(dbh)
+	if err != nil {
+		t.Fatalf("loadCounter: %v", err)
+	}
+
+	type week struct {
+		start, end  string
+		consumption float64
+	}
+	weeks := func(loc *time.Location) map[string]week {
+		if err := aggregateWeekly(dbh, c, loc); err != nil {
+			t.Fatalf("aggregateWeekly: %v", err)
+		}
+		rows, err := dbh.Query(`SELECT week, week_start, week_end, weekly_consumption FROM weekly_energy`)
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		defer rows.Close()
+		out := map[string]week{}
+		for rows.Next() {
+			var k string
+			var w week
+			if err := rows.Scan(&k, &w.start, &w.end, &w.consumption); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			out[k] = w
+		}
+		return out
+	}
+
+	got := weeks(berlin)
+	for k, want := range map[string]week{
+		"2020-W52": {"2020-12-21", "2020-12-27", 24},
+		"2020-W53": {"2020-12-28", "2021-01-03", 168},
+		"2021-W01": {"2021-01-04", "2021-01-10", 168},
+	} {
+		if got[k] != want {
+			t.Errorf("berlin %s = %+v, want %+v", k, got[k], want)
+		}
+	}
+
+	// in UTC endet 2020-W52 erst Montag 01:00 Berliner Zeit
+	got = weeks(time.UTC)
+	if w := got["2020-W52"]; w.consumption != 25 || w.start != "2020-12-21" {
+		t.Errorf("utc 2020-W52 = %+v", w)
+	}
+}
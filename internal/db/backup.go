@@ -72,3 +72,12 @@ func copyFile(src, dst string) error {
 	_, err = io.Copy(out, in)
 	return err
 }
+
+// SnapshotBackup schreibt per VACUUM INTO eine konsistente Kopie der
+// laufenden Datenbank, ohne Journal-Modus oder parallele Schreiber zu stören.
+func SnapshotBackup(db *sql.DB, backupPath string) error {
+	if _, err := db.Exec("VACUUM INTO ?", backupPath); err != nil {
+		return fmt.Errorf("VACUUM INTO fehlgeschlagen: %w", err)
+	}
+	return nil
+}
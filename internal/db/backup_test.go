@@ -0,0 +1,42 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotBackupWhileWALActive(t *testing.T) {
+	dir := t.TempDir()
+	dbh, err := sql.Open("sqlite3", filepath.Join(dir, "live.db")+"?_journal_mode=WAL")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer dbh.Close()
+	if err := CreateSchema(dbh); err != nil {
+		t.Fatalf("CreateSchema: %v", err)
+	}
+	if _, err := dbh.Exec(`INSERT INTO tasmota_data (device_id, timestamp_unix, power) VALUES ('plug1', 100, 5)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	path := filepath.Join(dir, "snapshot.db")
+	if err := SnapshotBackup(dbh, path); err != nil {
+		t.Fatalf("SnapshotBackup: %v", err)
+	}
+
+	var mode string
+	if err := dbh.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("journal_mode = %q (%v), want wal", mode, err)
+	}
+
+	snap, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open snapshot: %v", err)
+	}
+	defer snap.Close()
+	var n int
+	if err := snap.QueryRow("SELECT COUNT(*) FROM tasmota_data").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("snapshot rows = %d (%v), want 1", n, err)
+	}
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// -------------------------------------------------------------------
+// Vollständigkeit: Lücken und Abdeckung pro Quelle, Gerät und Tag
+// -------------------------------------------------------------------
+
+// Gap ist ein Zeitraum ohne Messwerte, länger als das erwartete Intervall
+type Gap struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Seconds int64     `json:"seconds"`
+}
+
+// DayCoverage ist die Abdeckung eines Geräts an einem Tag. Completeness ist
+// der Anteil des Tages (in Prozent), der nicht in einer Lücke liegt.
+type DayCoverage struct {
+	Source       string  `json:"source"`
+	Device       string  `json:"device"`
+	Day          string  `json:"day"`
+	Readings     int     `json:"readings"`
+	Completeness float64 `json:"completeness"`
+	Gaps         []Gap   `json:"gaps"`
+}
+
+// Coverage wertet table für [from, to) aus; Tage werden in loc gebildet.
+// Gemeldet werden alle bekannten Geräte der Quelle (Geräteregister und
+// Messwerte); ohne Messwert im Zeitraum mit 0 % und einer Lücke über den
+// ganzen Zeitraum. Hat die Quelle kein Gerät, steht sie selbst mit Gerät ""
+// im Bericht.
+func Coverage(db *sql.DB, source, table string, expected time.Duration, from, to time.Time, loc *time.Location) ([]DayCoverage, error) {
+	if _, ok := readingKeys[table]; !ok {
+		return nil, fmt.Errorf("keine Messwert-Tabelle: %s", table)
+	}
+	device := "''"
+	identity := ReadingIdentity(table)
+	if len(identity) > 0 {
+		device = "COALESCE(CAST(" + identity[0] + " AS TEXT), '')"
+	}
+	// ein Messwert vor from zeigt, ob der Zeitraum schon in einer Lücke beginnt
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT DISTINCT %s AS device, timestamp_unix FROM %s
+		WHERE timestamp_unix >= ? AND timestamp_unix < ?
+		ORDER BY device, timestamp_unix
+	`, device, table), from.Add(-expected).Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	readings := map[string][]time.Time{}
+	for rows.Next() {
+		var d string
+		var ts int64
+		if err := rows.Scan(&d, &ts); err != nil {
+			return nil, err
+		}
+		readings[d] = append(readings[d], time.Unix(ts, 0))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	devices := map[string]bool{}
+	for d := range readings {
+		devices[d] = true
+	}
+	if len(identity) > 0 {
+		// nur Geräte, die schon Messwerte geliefert haben (Zigbee2MQTT meldet
+		// z.B. auch Sensoren ohne Leistungswerte im Register)
+		known, err := db.Query(`
+			SELECT device_id FROM devices
+			WHERE source = ? AND last_seen IS NOT NULL AND first_seen < ?
+		`, source, to.Unix())
+		if err != nil {
+			return nil, err
+		}
+		defer known.Close()
+		for known.Next() {
+			var d string
+			if err := known.Scan(&d); err != nil {
+				return nil, err
+			}
+			devices[d] = true
+		}
+		if err := known.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(devices) == 0 {
+		devices[""] = true
+	}
+
+	out := []DayCoverage{}
+	for _, d := range slices.Sorted(maps.Keys(devices)) {
+		out = append(out, dayCoverage(source, d, readings[d], expected, from, to, loc)...)
+	}
+	return out, nil
+}
+
+// findGaps liefert die Lücken zwischen den Messwerten ts in [from, to)
+func findGaps(ts []time.Time, expected time.Duration, from, to time.Time) []Gap {
+	var gaps []Gap
+	cursor := from
+	for _, t := range ts {
+		if t.Before(from) {
+			cursor = t
+			continue
+		}
+		if t.Sub(cursor) > expected {
+			gaps = append(gaps, Gap{From: cursor, To: t})
+		}
+		cursor = t
+	}
+	if to.Sub(cursor) > expected {
+		gaps = append(gaps, Gap{From: cursor, To: to})
+	}
+	for i := range gaps {
+		if gaps[i].From.Before(from) {
+			gaps[i].From = from
+		}
+	}
+	return gaps
+}
+
+func dayCoverage(source, device string, ts []time.Time, expected time.Duration, from, to time.Time, loc *time.Location) []DayCoverage {
+	gaps := findGaps(ts, expected, from, to)
+
+	var out []DayCoverage
+	y, m, d := from.In(loc).Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		start, end := day, day.AddDate(0, 0, 1)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		c := DayCoverage{Source: source, Device: device, Day: day.Format("2006-01-02"), Gaps: []Gap{}}
+		for _, t := range ts {
+			if !t.Before(start) && t.Before(end) {
+				c.Readings++
+			}
+		}
+		var missing time.Duration
+		for _, g := range gaps {
+			gs, ge := g.From, g.To
+			if gs.Before(start) {
+				gs = start
+			}
+			if ge.After(end) {
+				ge = end
+			}
+			if ge.After(gs) {
+				c.Gaps = append(c.Gaps, Gap{From: gs.In(loc), To: ge.In(loc), Seconds: int64(ge.Sub(gs).Seconds())})
+				missing += ge.Sub(gs)
+			}
+		}
+		if span := end.Sub(start); span > 0 {
+			c.Completeness = math.Round(1000*(1-missing.Seconds()/span.Seconds())) / 10
+		}
+		out = append(out, c)
+	}
+	return out
+}
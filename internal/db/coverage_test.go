@@ -0,0 +1,133 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCoverageFindsGaps(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	loc := time.UTC
+	day := time.Date(2025, 11, 18, 0, 0, 0, 0, loc)
+	// alle 5 Minuten, aber Dienstag 06:00–12:00 offline
+	for ts := day; ts.Before(day.AddDate(0, 0, 2)); ts = ts.Add(5 * time.Minute) {
+		if ts.Hour() >= 6 && ts.Hour() < 12 && ts.Day() == 18 {
+			continue
+		}
+		if _, err := dbh.Exec(`INSERT INTO energy_data (timestamp_unix, e_in) VALUES (?, 1)`, ts.Unix()); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	report, err := Coverage(dbh, "wattwaechter", "energy_data", 6*time.Minute, day, day.AddDate(0, 0, 2), loc)
+	if err != nil {
+		t.Fatalf("Coverage: %v", err)
+	}
+	if len(report) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	tue, wed := report[0], report[1]
+	if tue.Day != "2025-11-18" || len(tue.Gaps) != 1 || tue.Completeness != 74.7 {
+		t.Fatalf("tuesday = %+v", tue)
+	}
+	if g := tue.Gaps[0]; !g.From.Equal(day.Add(5*time.Hour+55*time.Minute)) || !g.To.Equal(day.Add(12*time.Hour)) || g.Seconds != 21900 {
+		t.Fatalf("gap = %+v", g)
+	}
+	// letzter Wert 23:55, bis Mitternacht keine Lücke
+	if wed.Completeness != 100 || len(wed.Gaps) != 0 || wed.Readings != 288 {
+		t.Fatalf("wednesday = %+v", wed)
+	}
+}
+
+func TestCoverageReportsSilentSourcesAndDevices(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	day := time.Date(2025, 11, 18, 0, 0, 0, 0, time.UTC)
+	// plug1 hat gestern gemeldet, heute nicht; der Zigbee-Sensor nie
+	if err := TouchDevice(dbh, "tasmota", "plug1", day.Add(-time.Hour)); err != nil {
+		t.Fatalf("TouchDevice: %v", err)
+	}
+	if err := SetDeviceInfo(dbh, "zigbee", "Flur", []byte(`{"model":"WSDCGQ11LM"}`)); err != nil {
+		t.Fatalf("SetDeviceInfo: %v", err)
+	}
+	for ts := day; ts.Before(day.AddDate(0, 0, 1)); ts = ts.Add(5 * time.Minute) {
+		if _, err := dbh.Exec(`INSERT INTO tasmota_data (device_id, timestamp_unix, power) VALUES ('plug2', ?, 1)`, ts.Unix()); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	// Wattwaechter den ganzen Tag offline
+	ww, err := Coverage(dbh, "wattwaechter", "energy_data", 6*time.Minute, day, day.AddDate(0, 0, 1), time.UTC)
+	if err != nil {
+		t.Fatalf("Coverage: %v", err)
+	}
+	if len(ww) != 1 || ww[0].Completeness != 0 || ww[0].Readings != 0 || len(ww[0].Gaps) != 1 || ww[0].Gaps[0].Seconds != 86400 {
+		t.Fatalf("wattwaechter = %+v", ww)
+	}
+
+	tas, err := Coverage(dbh, "tasmota", "tasmota_data", 6*time.Minute, day, day.AddDate(0, 0, 1), time.UTC)
+	if err != nil {
+		t.Fatalf("Coverage: %v", err)
+	}
+	if len(tas) != 2 || tas[0].Device != "plug1" || tas[0].Completeness != 0 || len(tas[0].Gaps) != 1 ||
+		tas[1].Device != "plug2" || tas[1].Completeness != 100 {
+		t.Fatalf("tasmota = %+v", tas)
+	}
+
+	// Geräte ohne Messwerte zählen nicht, die Quelle erscheint trotzdem
+	zb, err := Coverage(dbh, "zigbee", "zigbee_data", 6*time.Minute, day, day.AddDate(0, 0, 1), time.UTC)
+	if err != nil {
+		t.Fatalf("Coverage: %v", err)
+	}
+	if len(zb) != 1 || zb[0].Device != "" || zb[0].Completeness != 0 {
+		t.Fatalf("zigbee = %+v", zb)
+	}
+}
+
+func TestAggregatesFlagIncompletePeriods(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	day := time.Date(2025, 11, 18, 0, 0, 0, 0, time.UTC)
+	for i, ts := range []time.Time{
+		day.Add(time.Hour), day.Add(2 * time.Hour),
+		day.Add(25 * time.Hour), day.Add(25*time.Hour + 5*time.Minute),
+	} {
+		if _, err := dbh.Exec(`INSERT INTO energy_data (timestamp_unix, e_in) VALUES (?, ?)`, ts.Unix(), 100+i); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	c, err := loadCounter(dbh)
+	if err != nil {
+		t.Fatalf("loadCounter: %v", err)
+	}
+	if err := aggregateDaily(dbh, c, time.UTC); err != nil {
+		t.Fatalf("aggregateDaily: %v", err)
+	}
+	if err := aggregateMonthly(dbh, c, time.UTC, 0.3); err != nil {
+		t.Fatalf("aggregateMonthly: %v", err)
+	}
+	if err := markIncomplete(dbh, 10*time.Minute, time.UTC, day.Add(25*time.Hour+10*time.Minute)); err != nil {
+		t.Fatalf("markIncomplete: %v", err)
+	}
+
+	got, err := DailyConsumptions(dbh, 10)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("daily = %+v %v", got, err)
+	}
+	// Lücke 02:00 bis 01:00 am Folgetag betrifft beide Tage
+	if got[0].Complete || got[1].Complete {
+		t.Fatalf("daily = %+v", got)
+	}
+
+	if err := markIncomplete(dbh, 24*time.Hour, time.UTC, day.Add(25*time.Hour+10*time.Minute)); err != nil {
+		t.Fatalf("markIncomplete: %v", err)
+	}
+	months, err := MonthlyCosts(dbh)
+	if err != nil || len(months) != 1 || !months[0].Complete {
+		t.Fatalf("monthly = %+v %v", months, err)
+	}
+}
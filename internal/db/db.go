@@ -4,9 +4,12 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -22,14 +25,28 @@ func Open(path string) (*sql.DB, error) {
 
 // InitDB erstellt Tabellen + Views und startet Aggregation
 func InitDB(db *sql.DB, cfg config.Config) error {
+	if err := CreateSchema(db); err != nil {
+		return err
+	}
+	startAggregationLoop(db, cfg)
+	return nil
+}
+
+// CreateSchema erstellt Tabellen + Views ohne Aggregations-Loop (für CLI-Befehle)
+func CreateSchema(db *sql.DB) error {
 	if err := createTables(db); err != nil {
 		return err
 	}
-	if err := createViews(db); err != nil {
+	if err := migrateColumns(db); err != nil {
 		return err
 	}
-	startAggregationLoop(db, cfg)
-	return nil
+	if err := createUniqueIndexes(db); err != nil {
+		return err
+	}
+	if err := seedDevices(db); err != nil {
+		return err
+	}
+	return createViews(db)
 }
 
 // -------------------------------------------------------------------
@@ -47,6 +64,16 @@ func createTables(db *sql.DB) error {
 			power INTEGER
 		);`,
 
+		`CREATE TABLE IF NOT EXISTS meter_data (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			meter_id TEXT,
+			timestamp_unix INTEGER,
+			timestamp_rfc3339 TEXT,
+			e_in REAL,
+			e_out REAL,
+			power REAL
+		);`,
+
 		`CREATE TABLE IF NOT EXISTS tasmota_data (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			device_id TEXT,
@@ -55,6 +82,30 @@ func createTables(db *sql.DB) error {
 			power INTEGER
 		);`,
 
+		`CREATE TABLE IF NOT EXISTS shelly_data (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id TEXT,
+			channel INTEGER,
+			timestamp_unix INTEGER,
+			timestamp_rfc3339 TEXT,
+			power REAL,
+			voltage REAL,
+			current REAL,
+			energy_total REAL,
+			energy_returned REAL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS zigbee_data (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id TEXT,
+			timestamp_unix INTEGER,
+			timestamp_rfc3339 TEXT,
+			power REAL,
+			energy_total REAL,
+			voltage REAL,
+			current REAL
+		);`,
+
 		`CREATE TABLE IF NOT EXISTS solar_data (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			timestamp_unix INTEGER,
@@ -81,7 +132,9 @@ func createTables(db *sql.DB) error {
 
 		`CREATE TABLE IF NOT EXISTS weekly_energy_raw (
 			week TEXT PRIMARY KEY,
-			weekly_consumption REAL
+			weekly_consumption REAL,
+			week_start TEXT,
+			week_end TEXT
 		);`,
 
 		`CREATE TABLE IF NOT EXISTS monthly_energy_cost_raw (
@@ -95,6 +148,38 @@ func createTables(db *sql.DB) error {
 			consumption REAL,
 			cost REAL
 		);`,
+
+		`CREATE TABLE IF NOT EXISTS devices (
+			source TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			friendly_name TEXT,
+			room TEXT,
+			category TEXT,
+			nominal_power REAL,
+			first_seen INTEGER,
+			last_seen INTEGER,
+			info TEXT,
+			state TEXT,
+			PRIMARY KEY (source, device_id)
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS quarantine (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			received_unix INTEGER,
+			source TEXT,
+			device_id TEXT,
+			target_table TEXT,
+			timestamp_unix INTEGER,
+			metric TEXT,
+			value REAL,
+			reason TEXT,
+			row_json TEXT
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY,
+			value TEXT
+		);`,
 	}
 
 	for _, t := range tables {
@@ -105,6 +190,86 @@ func createTables(db *sql.DB) error {
 	return nil
 }
 
+// -------------------------------------------------------------------
+// Spalten, die nach der ersten Version hinzugekommen sind
+// -------------------------------------------------------------------
+
+var addedColumns = []struct {
+	table  string
+	column string
+	def    string
+}{
+	{"tasmota_data", "energy_total", "REAL"},
+
+	// optionale Größen von Drehstrom-Leseköpfen (Wattwaechter)
+	{"energy_data", "power_l1", "REAL"},
+	{"energy_data", "power_l2", "REAL"},
+	{"energy_data", "power_l3", "REAL"},
+	{"energy_data", "voltage_l1", "REAL"},
+	{"energy_data", "voltage_l2", "REAL"},
+	{"energy_data", "voltage_l3", "REAL"},
+	{"energy_data", "current_l1", "REAL"},
+	{"energy_data", "current_l2", "REAL"},
+	{"energy_data", "current_l3", "REAL"},
+	{"energy_data", "frequency", "REAL"},
+	{"energy_data", "e_in_t1", "REAL"},
+	{"energy_data", "e_in_t2", "REAL"},
+	{"energy_data", "e_out_t1", "REAL"},
+	{"energy_data", "e_out_t2", "REAL"},
+
+	// 0, wenn im Zeitraum Lücken in energy_data liegen
+	{"daily_energy_raw", "complete", "INTEGER"},
+	{"weekly_energy_raw", "complete", "INTEGER"},
+	{"monthly_energy_cost_raw", "complete", "INTEGER"},
+	{"yearly_energy_cost_current_raw", "complete", "INTEGER"},
+
+	// ISO-Woche: Montag und Sonntag (YYYY-MM-DD)
+	{"weekly_energy_raw", "week_start", "TEXT"},
+	{"weekly_energy_raw", "week_end", "TEXT"},
+}
+
+func migrateColumns(db *sql.DB) error {
+	for _, c := range addedColumns {
+		exists, err := columnExists(db, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.table, c.column, c.def)); err != nil {
+			return fmt.Errorf("Fehler beim Hinzufügen von %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			typ     string
+			notNull int
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
 // -------------------------------------------------------------------
 // Views erzeugen
 // -------------------------------------------------------------------
@@ -115,28 +280,72 @@ func createViews(db *sql.DB) error {
 
 		`DROP VIEW IF EXISTS daily_energy;
 		CREATE VIEW IF NOT EXISTS daily_energy AS
-			SELECT day, daily_consumption
+			SELECT day, daily_consumption, COALESCE(complete, 1) AS complete
 			FROM daily_energy_raw;`,
 
 		`DROP VIEW IF EXISTS weekly_energy;
 		CREATE VIEW IF NOT EXISTS weekly_energy AS
-			SELECT week, weekly_consumption
+			SELECT week, week_start, week_end, weekly_consumption, COALESCE(complete, 1) AS complete
 			FROM weekly_energy_raw;`,
 
 		`DROP VIEW IF EXISTS monthly_energy_cost;
 		CREATE VIEW IF NOT EXISTS monthly_energy_cost AS
 			SELECT month,
 			       consumption AS monthly_consumption,
-			       cost AS monthly_cost
+			       cost AS monthly_cost,
+			       COALESCE(complete, 1) AS complete
 			FROM monthly_energy_cost_raw;`,
 
+		// veraltet: strftime('now') rechnet in UTC, [time] timezone gilt hier
+		// nicht. API und Grafana filtern yearly_energy_cost selbst (CurrentYearCost).
 		`DROP VIEW IF EXISTS yearly_energy_cost_current;
 		CREATE VIEW yearly_energy_cost_current AS
 		SELECT
 			consumption AS total_consumption,
-    		cost AS total_cost
+    		cost AS total_cost,
+			COALESCE(complete, 1) AS complete
 		FROM yearly_energy_cost_current_raw
 		WHERE year = strftime('%Y','now');`,
+
+		`DROP VIEW IF EXISTS yearly_energy_cost;
+		CREATE VIEW yearly_energy_cost AS
+			SELECT year,
+			       consumption AS yearly_consumption,
+			       cost AS yearly_cost,
+			       COALESCE(complete, 1) AS complete
+			FROM yearly_energy_cost_current_raw;`,
+
+		// Vormonat, gleicher Monat im Vorjahr (Δ in %) und gleitende
+		// Summe der letzten 12 Monate einschließlich des Monats
+		`DROP VIEW IF EXISTS monthly_energy_comparison;
+		CREATE VIEW monthly_energy_comparison AS
+			SELECT m.month,
+			       m.consumption AS monthly_consumption,
+			       m.cost AS monthly_cost,
+			       COALESCE(m.complete, 1) AS complete,
+			       p.consumption AS prev_month_consumption,
+			       ROUND((m.consumption - p.consumption) * 100.0 / NULLIF(p.consumption, 0), 1) AS mom_delta_pct,
+			       y.consumption AS last_year_consumption,
+			       ROUND((m.consumption - y.consumption) * 100.0 / NULLIF(y.consumption, 0), 1) AS yoy_delta_pct,
+			       (SELECT SUM(r.consumption) FROM monthly_energy_cost_raw r
+			        WHERE r.month > strftime('%Y-%m', m.month || '-01', '-12 months') AND r.month <= m.month) AS rolling_12m_consumption,
+			       (SELECT SUM(r.cost) FROM monthly_energy_cost_raw r
+			        WHERE r.month > strftime('%Y-%m', m.month || '-01', '-12 months') AND r.month <= m.month) AS rolling_12m_cost
+			FROM monthly_energy_cost_raw m
+			LEFT JOIN monthly_energy_cost_raw p ON p.month = strftime('%Y-%m', m.month || '-01', '-1 month')
+			LEFT JOIN monthly_energy_cost_raw y ON y.month = strftime('%Y-%m', m.month || '-01', '-12 months');`,
+
+		`DROP VIEW IF EXISTS yearly_energy_comparison;
+		CREATE VIEW yearly_energy_comparison AS
+			SELECT y.year,
+			       y.consumption AS yearly_consumption,
+			       y.cost AS yearly_cost,
+			       COALESCE(y.complete, 1) AS complete,
+			       p.consumption AS prev_year_consumption,
+			       p.cost AS prev_year_cost,
+			       ROUND((y.consumption - p.consumption) * 100.0 / NULLIF(p.consumption, 0), 1) AS yoy_delta_pct
+			FROM yearly_energy_cost_current_raw y
+			LEFT JOIN yearly_energy_cost_current_raw p ON p.year = y.year - 1;`,
 	}
 
 	for _, v := range views {
@@ -149,134 +358,223 @@ func createViews(db *sql.DB) error {
 }
 
 // -------------------------------------------------------------------
-// Aggregationsfunktionen – aktualisieren vorhandene Einträge per REPLACE
+// Aggregationsfunktionen – bauen die Tabellen bei jedem Lauf neu auf.
+// Perioden sind Kalendertage, ISO-Wochen, Monate und Jahre in loc.
 // -------------------------------------------------------------------
 
-func aggregateDaily(db *sql.DB) error {
-	query := `
-	INSERT OR REPLACE INTO daily_energy_raw (day, daily_consumption)
-	SELECT
-		strftime('%Y-%m-%d', datetime(timestamp_unix, 'unixepoch')) AS day,
-		MAX(e_in) - MIN(e_in) AS consumption
-	FROM energy_data
-	WHERE timestamp_unix > 0
-	GROUP BY day
-	HAVING consumption >= 0;
-	`
-	_, err := db.Exec(query)
-	return err
+func aggregateDaily(db *sql.DB, c counter, loc *time.Location) error {
+	return replacePeriods(db, `DELETE FROM daily_energy_raw`,
+		`INSERT INTO daily_energy_raw (day, daily_consumption) VALUES (?, ?)`,
+		c.periodTotals(loc, dayKey), func(p periodTotal) []any {
+			return []any{p.key, p.consumption}
+		})
 }
 
-func aggregateWeekly(db *sql.DB) error {
-	query := `
-	INSERT OR REPLACE INTO weekly_energy_raw (week, weekly_consumption)
-	SELECT
-		strftime('%Y-%W', datetime(timestamp_unix, 'unixepoch')) AS week,
-		MAX(e_in) - MIN(e_in) AS consumption
-	FROM energy_data
-	WHERE timestamp_unix > 0
-	GROUP BY week
-	HAVING consumption >= 0;
-	`
-	_, err := db.Exec(query)
-	return err
+func aggregateWeekly(db *sql.DB, c counter, loc *time.Location) error {
+	return replacePeriods(db, `DELETE FROM weekly_energy_raw`,
+		`INSERT INTO weekly_energy_raw (week, weekly_consumption, week_start, week_end) VALUES (?, ?, ?, ?)`,
+		c.periodTotals(loc, isoWeek), func(p periodTotal) []any {
+			monday := weekStart(p.start)
+			return []any{p.key, p.consumption, dayKey(monday), dayKey(monday.AddDate(0, 0, 6))}
+		})
 }
 
-func aggregateMonthly(db *sql.DB, perKWh float64) error {
-	// Rebuild the monthly table from scratch so old/invalid rows (e.g. epoch 0) vanish.
-	_, _ = db.Exec(`DELETE FROM monthly_energy_cost_raw;`)
+func aggregateMonthly(db *sql.DB, c counter, loc *time.Location, perKWh float64) error {
+	return replacePeriods(db, `DELETE FROM monthly_energy_cost_raw`,
+		`INSERT INTO monthly_energy_cost_raw (month, consumption, cost) VALUES (?, ?, ?)`,
+		c.periodTotals(loc, monthKey), func(p periodTotal) []any {
+			return []any{p.key, p.consumption, p.consumption * perKWh}
+		})
+}
 
-	query := `
-	WITH month_edges AS (
-		SELECT
-			month,
-			MAX(CASE WHEN rn_asc = 1 THEN e_in END) AS start_e_in,
-			MAX(CASE WHEN rn_desc = 1 THEN e_in END) AS end_e_in
-		FROM (
-			SELECT
-				strftime('%Y-%m', datetime(timestamp_unix, 'unixepoch')) AS month,
-				e_in,
-				ROW_NUMBER() OVER (
-					PARTITION BY strftime('%Y-%m', datetime(timestamp_unix, 'unixepoch'))
-					ORDER BY timestamp_unix
-				) AS rn_asc,
-				ROW_NUMBER() OVER (
-					PARTITION BY strftime('%Y-%m', datetime(timestamp_unix, 'unixepoch'))
-					ORDER BY timestamp_unix DESC
-				) AS rn_desc
-			FROM energy_data
-			WHERE timestamp_unix > 0
-		)
-		GROUP BY month
-	),
-	with_next AS (
-		SELECT
-			month,
-			start_e_in,
-			end_e_in,
-			LEAD(start_e_in) OVER (ORDER BY month) AS next_month_start
-		FROM month_edges
-	),
-	consumption_calc AS (
-		SELECT
-			month,
-			CASE
-				WHEN next_month_start IS NOT NULL THEN next_month_start - start_e_in
-				ELSE end_e_in - start_e_in
-			END AS consumption
-		FROM with_next
-	)
-	INSERT INTO monthly_energy_cost_raw (month, consumption, cost)
-	SELECT
-		month,
-		CASE WHEN consumption < 0 THEN 0 ELSE consumption END AS consumption,
-		CASE WHEN consumption < 0 THEN 0 ELSE consumption END * ? AS cost
-	FROM consumption_calc;
-	`
-	_, err := db.Exec(query, perKWh)
-	return err
+func aggregateYearly(db *sql.DB, c counter, loc *time.Location, perKWh float64) error {
+	return replacePeriods(db, `DELETE FROM yearly_energy_cost_current_raw`,
+		`INSERT INTO yearly_energy_cost_current_raw (year, consumption, cost) VALUES (?, ?, ?)`,
+		c.periodTotals(loc, yearKey), func(p periodTotal) []any {
+			return []any{p.key, p.consumption, p.consumption * perKWh}
+		})
+}
+
+// replacePeriods baut eine Aggregat-Tabelle neu auf, damit alte oder
+// ungültige Zeilen (z.B. Epoch 0) verschwinden; row liefert die Werte für
+// insert
+func replacePeriods(db *sql.DB, clear, insert string, totals []periodTotal, row func(periodTotal) []any) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(clear); err != nil {
+		return err
+	}
+	for _, p := range totals {
+		if _, err := tx.Exec(insert, row(p)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// aggregatePeriods ordnet einem Zeitpunkt die Schlüssel der Aggregate zu
+var aggregatePeriods = []struct {
+	table, column string
+	key           func(time.Time) string
+}{
+	{"daily_energy_raw", "day", dayKey},
+	{"weekly_energy_raw", "week", isoWeek},
+	{"monthly_energy_cost_raw", "month", monthKey},
+	{"yearly_energy_cost_current_raw", "year", yearKey},
+}
+
+func dayKey(t time.Time) string   { return t.Format("2006-01-02") }
+func monthKey(t time.Time) string { return t.Format("2006-01") }
+func yearKey(t time.Time) string  { return t.Format("2006") }
+
+// isoWeek liefert die Woche nach ISO 8601 (z.B. "2025-W01"). Das Jahr ist
+// das der Woche: der 29.12.2025 gehört zu 2026-W01, der 1.1.2021 zu 2020-W53.
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
 }
 
-func aggregateYearly(db *sql.DB, perKWh float64) error {
-	query := `
-	INSERT OR REPLACE INTO yearly_energy_cost_current_raw (year, consumption, cost)
-	SELECT
-		strftime('%Y', datetime(timestamp_unix, 'unixepoch')) AS year,
-		MAX(e_in) - MIN(e_in) AS consumption,
-		(MAX(e_in) - MIN(e_in)) * ? AS cost
-	FROM energy_data
-	WHERE timestamp_unix > 0
-	GROUP BY year
-	HAVING consumption >= 0;
-	`
-	_, err := db.Exec(query, perKWh)
-	return err
+// weekStart liefert den Montag der Woche von t (00:00 in der Zone von t)
+func weekStart(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+}
+
+// markIncomplete setzt complete = 0 für alle Zeiträume (in loc), in denen
+// zwischen zwei Zählerständen (oder seit dem letzten bis now) mehr als
+// expected liegt
+func markIncomplete(db *sql.DB, expected time.Duration, loc *time.Location, now time.Time) error {
+	rows, err := db.Query(`
+		SELECT prev, ts FROM (
+			SELECT timestamp_unix AS ts, LAG(timestamp_unix) OVER (ORDER BY timestamp_unix) AS prev
+			FROM energy_data WHERE timestamp_unix > 0
+		) WHERE ts - prev > ?
+		UNION ALL
+		SELECT MAX(timestamp_unix), ? FROM energy_data
+		WHERE timestamp_unix > 0 HAVING ? - MAX(timestamp_unix) > ?
+	`, int64(expected.Seconds()), now.Unix(), now.Unix(), int64(expected.Seconds()))
+	if err != nil {
+		return err
+	}
+	incomplete := make([]map[string]bool, len(aggregatePeriods))
+	for i := range incomplete {
+		incomplete[i] = map[string]bool{}
+	}
+	for rows.Next() {
+		var from, to int64
+		if err := rows.Scan(&from, &to); err != nil {
+			rows.Close()
+			return err
+		}
+		// alle Tage, die die Lücke berührt
+		last := time.Unix(to-1, 0).In(loc)
+		for t := time.Unix(from, 0).In(loc); !t.After(last); t = t.AddDate(0, 0, 1) {
+			for i, p := range aggregatePeriods {
+				incomplete[i][p.key(t)] = true
+			}
+		}
+		for i, p := range aggregatePeriods {
+			incomplete[i][p.key(last)] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i, p := range aggregatePeriods {
+		if _, err := tx.Exec(`UPDATE ` + p.table + ` SET complete = 1`); err != nil {
+			return err
+		}
+		for key := range incomplete[i] {
+			if _, err := tx.Exec(`UPDATE `+p.table+` SET complete = 0 WHERE `+p.column+` = ?`, key); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
 }
 
 // -------------------------------------------------------------------
 // Loop: Alle 10 Minuten Aggregationen
 // -------------------------------------------------------------------
 
+var (
+	hooksMu          sync.Mutex
+	aggregationHooks []func()
+)
+
+// AddAggregationHook registriert eine Funktion, die nach jedem
+// Aggregationslauf ausgeführt wird (z.B. Veröffentlichung per MQTT)
+func AddAggregationHook(fn func()) {
+	hooksMu.Lock()
+	aggregationHooks = append(aggregationHooks, fn)
+	hooksMu.Unlock()
+}
+
 func startAggregationLoop(db *sql.DB, cfg config.Config) {
 	go func() {
 		ticker := time.NewTicker(10 * time.Minute)
 		defer ticker.Stop()
 
 		for {
-			if err := aggregateDaily(db); err != nil {
-				log.Printf("Fehler tägliche Aggregation: %v", err)
-			}
-			if err := aggregateWeekly(db); err != nil {
-				log.Printf("Fehler wöchentliche Aggregation: %v", err)
-			}
-			if err := aggregateMonthly(db, cfg.Cost.PerKWh); err != nil {
-				log.Printf("Fehler monatliche Aggregation: %v", err)
-			}
-			if err := aggregateYearly(db, cfg.Cost.PerKWh); err != nil {
-				log.Printf("Fehler jährliche Aggregation: %v", err)
-			}
-
+			RunAggregations(db, cfg)
 			<-ticker.C
 		}
 	}()
 }
+
+// RunAggregations führt alle Aggregationen einmal aus
+func RunAggregations(db *sql.DB, cfg config.Config) {
+	start := time.Now()
+	perKWh := Tariff(db, cfg.Cost.PerKWh)
+	loc := cfg.Time.Location()
+
+	if c, err := loadCounter(db); err != nil {
+		log.Printf("Fehler beim Lesen der Zählerstände: %v", err)
+	} else {
+		if err := aggregateDaily(db, c, loc); err != nil {
+			log.Printf("Fehler tägliche Aggregation: %v", err)
+		}
+		if err := aggregateWeekly(db, c, loc); err != nil {
+			log.Printf("Fehler wöchentliche Aggregation: %v", err)
+		}
+		if err := aggregateMonthly(db, c, loc, perKWh); err != nil {
+			log.Printf("Fehler monatliche Aggregation: %v", err)
+		}
+		if err := aggregateYearly(db, c, loc, perKWh); err != nil {
+			log.Printf("Fehler jährliche Aggregation: %v", err)
+		}
+	}
+	if err := markIncomplete(db, cfg.Health.ExpectedIntervalFor("wattwaechter"), loc, time.Now()); err != nil {
+		log.Printf("Fehler Vollständigkeit der Aggregate: %v", err)
+	}
+
+	metrics.AggregationDuration.Set(time.Since(start).Seconds())
+
+	hooksMu.Lock()
+	hooks := append([]func(){}, aggregationHooks...)
+	hooksMu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// FileSize liefert die Größe der DB inkl. WAL-Datei in Bytes
+func FileSize(path string) int64 {
+	var size int64
+	for _, p := range []string{path, path + "-wal"} {
+		if fi, err := os.Stat(p); err == nil {
+			size += fi.Size()
+		}
+	}
+	return size
+}
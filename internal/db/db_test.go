@@ -28,7 +28,7 @@ func TestAggregateIgnoresOldTimestampsAndReplacesValues(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
 
-	// Insert readings: one invalid (old epoch), two valid days across two months.
+	// Insert readings: one invalid (old epoch), two days across a month boundary.
 	insert := `
 		INSERT INTO energy_data (timestamp_unix, timestamp_rfc3339, e_in, e_out, power)
 		VALUES (?, ?, ?, ?, ?);
@@ -38,11 +38,11 @@ func TestAggregateIgnoresOldTimestampsAndReplacesValues(t *testing.T) {
 		eIn float64
 		pwr int
 	}{
-		{time.Unix(-3600, 0), 10, 0},                            // should be ignored
-		{time.Date(2025, 11, 1, 8, 0, 0, 0, time.UTC), 100, 0},  // start of Nov
-		{time.Date(2025, 11, 1, 20, 0, 0, 0, time.UTC), 105, 0}, // end of same day -> 5 kWh
-		{time.Date(2025, 12, 2, 9, 0, 0, 0, time.UTC), 200, 0},  // start of Dec
-		{time.Date(2025, 12, 2, 21, 0, 0, 0, time.UTC), 210, 0}, // end of same day -> 10 kWh
+		{time.Unix(-3600, 0), 10, 0}, // should be ignored
+		{time.Date(2025, 11, 30, 6, 0, 0, 0, time.UTC), 100, 0},
+		{time.Date(2025, 11, 30, 18, 0, 0, 0, time.UTC), 106, 0},
+		{time.Date(2025, 12, 1, 6, 0, 0, 0, time.UTC), 112, 0}, // midnight interpolated: 109
+		{time.Date(2025, 12, 1, 18, 0, 0, 0, time.UTC), 120, 0},
 	}
 	for _, r := range rows {
 		if _, err := db.Exec(insert, r.ts.Unix(), r.ts.Format(time.RFC3339), r.eIn, 0, r.pwr); err != nil {
@@ -50,13 +50,22 @@ func TestAggregateIgnoresOldTimestampsAndReplacesValues(t *testing.T) {
 		}
 	}
 
-	if err := aggregateDaily(db); err != nil {
+	// stale row from an earlier run must disappear
+	if _, err := db.Exec(`INSERT INTO daily_energy_raw (day, daily_consumption) VALUES ('1970-01-01', 10)`); err != nil {
+		t.Fatalf("insert stale: %v", err)
+	}
+
+	c, err := loadCounter(db)
+	if err != nil {
+		t.Fatalf("loadCounter: %v", err)
+	}
+	if err := aggregateDaily(db, c, time.UTC); err != nil {
 		t.Fatalf("aggregateDaily: %v", err)
 	}
-	if err := aggregateMonthly(db, 1.0); err != nil { // cost = consumption for easy asserts
+	if err := aggregateMonthly(db, c, time.UTC, 1.0); err != nil { // cost = consumption for easy asserts
 		t.Fatalf("aggregateMonthly: %v", err)
 	}
-	if err := aggregateYearly(db, 1.0); err != nil {
+	if err := aggregateYearly(db, c, time.UTC, 1.0); err != nil {
 		t.Fatalf("aggregateYearly: %v", err)
 	}
 
@@ -90,18 +99,20 @@ func TestAggregateIgnoresOldTimestampsAndReplacesValues(t *testing.T) {
 		}
 	}
 
+	// consumption between the last reading of a day and the first of the
+	// next is split at midnight, so days add up to months and years
 	checkTable("daily_energy_raw", "day", "daily_consumption", map[string]float64{
-		"2025-11-01": 5,
-		"2025-12-02": 10,
+		"2025-11-30": 9,
+		"2025-12-01": 11,
 	})
 
 	checkTable("monthly_energy_cost_raw", "month", "consumption", map[string]float64{
-		"2025-11": 100, // consumption is measured between month boundaries
-		"2025-12": 10,
+		"2025-11": 9,
+		"2025-12": 11,
 	})
 
 	checkTable("yearly_energy_cost_current_raw", "year", "consumption", map[string]float64{
-		"2025": 110, // max-min over the whole year (210 - 100)
+		"2025": 20,
 	})
 }
 
@@ -124,6 +135,7 @@ func TestInitDBCreatesSchema(t *testing.T) {
 		"energy_data", "tasmota_data", "solar_data", "solar_meta",
 		"daily_energy_raw", "weekly_energy_raw",
 		"monthly_energy_cost_raw", "yearly_energy_cost_current_raw",
+		"settings",
 	}
 	for _, tbl := range tables {
 		var name string
@@ -133,7 +145,8 @@ func TestInitDBCreatesSchema(t *testing.T) {
 		}
 	}
 
-	views := []string{"daily_energy", "weekly_energy", "monthly_energy_cost", "yearly_energy_cost_current"}
+	views := []string{"daily_energy", "weekly_energy", "monthly_energy_cost", "yearly_energy_cost_current",
+		"yearly_energy_cost", "monthly_energy_comparison", "yearly_energy_comparison"}
 	for _, view := range views {
 		var name string
 		err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type='view' AND name=?`, view).Scan(&name)
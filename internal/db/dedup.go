@@ -0,0 +1,188 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/mattn/go-sqlite3"
+)
+
+// -------------------------------------------------------------------
+// Eindeutige Messwerte je Quelle, Gerät und Geräte-Zeitstempel
+// -------------------------------------------------------------------
+
+// readingKeys ist der eindeutige Schlüssel jeder Rohdatentabelle. Die
+// Tabelle steht für die Quelle, energy_data kennt nur einen Zähler.
+var readingKeys = map[string][]string{
+	"energy_data":  {"timestamp_unix"},
+	"meter_data":   {"meter_id", "timestamp_unix"},
+	"tasmota_data": {"device_id", "timestamp_unix"},
+	"shelly_data":  {"device_id", "channel", "timestamp_unix"},
+	"zigbee_data":  {"device_id", "timestamp_unix"},
+	"solar_data":   {"device_id", "channel", "metric", "timestamp_unix"},
+}
+
+// ErrDuplicate meldet einen schon gespeicherten Messwert (on_conflict = error)
+var ErrDuplicate = errors.New("Messwert bereits gespeichert")
+
+// createUniqueIndexes legt die Schlüssel an. Ältere Datenbanken können
+// Duplikate enthalten; die Migration löscht nichts, sondern lässt den Index
+// der betroffenen Tabelle weg, bis "mqttlogger dedup --apply" bereinigt.
+func createUniqueIndexes(db *sql.DB) error {
+	for _, table := range slices.Sorted(maps.Keys(readingKeys)) {
+		index := "ux_" + table + "_reading"
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?`, index).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+
+		dups, err := countDuplicates(db, table)
+		if err != nil {
+			return fmt.Errorf("Duplikate in %s zählen: %w", table, err)
+		}
+		if dups > 0 {
+			log.Printf("%s: %d doppelte Messwerte, Index %s nicht angelegt (bereinigen mit: mqttlogger dedup --apply)", table, dups, index)
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`CREATE UNIQUE INDEX %s ON %s (%s)`, index, table, strings.Join(readingKeys[table], ", "))); err != nil {
+			return fmt.Errorf("Index %s: %w", index, err)
+		}
+	}
+	return nil
+}
+
+// keysSet ist die WHERE-Bedingung "alle Schlüsselspalten gesetzt". GROUP BY
+// fasst NULL-Werte zusammen, der UNIQUE-Index lässt sie aber mehrfach zu.
+func keysSet(table string) string {
+	conds := make([]string, len(readingKeys[table]))
+	for i, k := range readingKeys[table] {
+		conds[i] = k + " IS NOT NULL"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// countDuplicates zählt die überzähligen Zeilen mit gleichem Schlüssel
+func countDuplicates(db Execer, table string) (int, error) {
+	var n int
+	err := db.QueryRow(fmt.Sprintf(`
+		SELECT COALESCE(SUM(n - 1), 0) FROM (
+			SELECT COUNT(*) AS n FROM %s WHERE %s GROUP BY %s HAVING n > 1
+		)
+	`, table, keysSet(table), strings.Join(readingKeys[table], ", "))).Scan(&n)
+	return n, err
+}
+
+// TableDuplicates ist die Zahl doppelter Messwerte einer Tabelle
+type TableDuplicates struct {
+	Table string
+	Rows  int
+}
+
+// Duplicates listet die Tabellen mit doppelten Messwerten
+func Duplicates(db *sql.DB) ([]TableDuplicates, error) {
+	var out []TableDuplicates
+	for _, table := range slices.Sorted(maps.Keys(readingKeys)) {
+		n, err := countDuplicates(db, table)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", table, err)
+		}
+		if n > 0 {
+			out = append(out, TableDuplicates{Table: table, Rows: n})
+		}
+	}
+	return out, nil
+}
+
+// RemoveDuplicates löscht doppelte Messwerte (die erste Zeile je Schlüssel
+// bleibt) und legt danach die fehlenden Indizes an
+func RemoveDuplicates(db *sql.DB) ([]TableDuplicates, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var out []TableDuplicates
+	for _, table := range slices.Sorted(maps.Keys(readingKeys)) {
+		res, err := tx.Exec(fmt.Sprintf(`
+			DELETE FROM %[1]s WHERE %[2]s AND id NOT IN (
+				SELECT MIN(id) FROM %[1]s WHERE %[2]s GROUP BY %[3]s
+			)
+		`, table, keysSet(table), strings.Join(readingKeys[table], ", ")))
+		if err != nil {
+			return nil, fmt.Errorf("Duplikate in %s entfernen: %w", table, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			out = append(out, TableDuplicates{Table: table, Rows: int(n)})
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return out, createUniqueIndexes(db)
+}
+
+// Execer ist *sql.DB oder *sql.Tx
+type Execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// InsertReading schreibt eine Zeile in eine Rohdatentabelle. onConflict
+// entscheidet über Duplikate: ignore verwirft sie (stored = false), replace
+// überschreibt, merge übernimmt nur gesetzte Werte, error liefert ErrDuplicate.
+func InsertReading(ex Execer, table, onConflict string, columns []string, args ...any) (stored bool, err error) {
+	keys, ok := readingKeys[table]
+	if !ok {
+		return false, fmt.Errorf("keine Messwert-Tabelle: %s", table)
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (?%s)`,
+		table, strings.Join(columns, ", "), strings.Repeat(", ?", len(columns)-1))
+
+	plain := query
+
+	var set []string
+	for _, c := range columns {
+		if slices.Contains(keys, c) {
+			continue
+		}
+		switch onConflict {
+		case config.ConflictReplace:
+			set = append(set, fmt.Sprintf("%[1]s = excluded.%[1]s", c))
+		case config.ConflictMerge:
+			set = append(set, fmt.Sprintf("%[1]s = COALESCE(excluded.%[1]s, %[1]s)", c))
+		}
+	}
+	switch onConflict {
+	case config.ConflictError:
+	case config.ConflictReplace, config.ConflictMerge:
+		query += fmt.Sprintf(` ON CONFLICT(%s) DO UPDATE SET %s`, strings.Join(keys, ", "), strings.Join(set, ", "))
+	default:
+		query += fmt.Sprintf(` ON CONFLICT(%s) DO NOTHING`, strings.Join(keys, ", "))
+	}
+
+	res, err := ex.Exec(query, args...)
+	if err != nil && strings.Contains(err.Error(), "does not match any PRIMARY KEY or UNIQUE constraint") {
+		// Index fehlt wegen Altdaten-Duplikaten (siehe createUniqueIndexes)
+		res, err = ex.Exec(plain, args...)
+	}
+	if err != nil {
+		var se sqlite3.Error
+		if errors.As(err, &se) && se.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return false, ErrDuplicate
+		}
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+func TestInsertReadingOnConflict(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	cols := []string{"device_id", "channel", "timestamp_unix", "power", "energy_total"}
+	insert := func(mode string, power, energy any) (bool, error) {
+		return InsertReading(dbh, "shelly_data", mode, cols, "plug", 0, 1000, power, energy)
+	}
+	row := func() (p, e sql.NullFloat64) {
+		t.Helper()
+		if err := dbh.QueryRow(`SELECT power, energy_total FROM shelly_data`).Scan(&p, &e); err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		return p, e
+	}
+
+	if ok, err := insert(config.ConflictIgnore, 10, nil); !ok || err != nil {
+		t.Fatalf("first insert: %v %v", ok, err)
+	}
+	if ok, err := insert(config.ConflictIgnore, 20, nil); ok || err != nil {
+		t.Fatalf("ignore: %v %v", ok, err)
+	}
+	if p, _ := row(); p.Float64 != 10 {
+		t.Fatalf("ignore changed power: %v", p)
+	}
+
+	if _, err := insert(config.ConflictMerge, nil, 2.5); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if p, e := row(); p.Float64 != 10 || e.Float64 != 2.5 {
+		t.Fatalf("merge: %v %v", p, e)
+	}
+
+	if _, err := insert(config.ConflictReplace, 30, nil); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if p, e := row(); p.Float64 != 30 || e.Valid {
+		t.Fatalf("replace: %v %v", p, e)
+	}
+
+	if _, err := insert(config.ConflictError, 40, nil); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("error mode: %v", err)
+	}
+
+	var n int
+	if err := dbh.QueryRow(`SELECT COUNT(*) FROM shelly_data`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("rows = %d %v", n, err)
+	}
+}
+
+func TestUniqueIndexKeepsDuplicatesUntilDedup(t *testing.T) {
+	dbh, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer dbh.Close()
+	dbh.SetMaxOpenConns(1)
+
+	if err := createTables(dbh); err != nil {
+		t.Fatalf("createTables: %v", err)
+	}
+	if err := migrateColumns(dbh); err != nil {
+		t.Fatalf("migrateColumns: %v", err)
+	}
+	if _, err := dbh.Exec(`
+		INSERT INTO tasmota_data (device_id, timestamp_unix, power) VALUES
+			('plug', 1000, 1), ('plug', 1000, 2), ('plug', 2000, 3),
+			(NULL, 3000, 4), (NULL, 3000, 5);
+		INSERT INTO zigbee_data (device_id, timestamp_unix, power) VALUES ('fridge', 1000, 1);
+	`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := dbh.QueryRow(`SELECT COUNT(*) FROM tasmota_data`).Scan(&n); err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		return n
+	}
+	hasIndex := func(table string) bool {
+		t.Helper()
+		var n int
+		if err := dbh.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?`, "ux_"+table+"_reading").Scan(&n); err != nil {
+			t.Fatalf("index: %v", err)
+		}
+		return n > 0
+	}
+
+	// Migration löscht nichts und lässt nur den betroffenen Index weg
+	if err := CreateSchema(dbh); err != nil {
+		t.Fatalf("CreateSchema: %v", err)
+	}
+	if n := count(); n != 5 {
+		t.Fatalf("migration deleted rows: %d left", n)
+	}
+	if hasIndex("tasmota_data") || !hasIndex("zigbee_data") {
+		t.Fatalf("indexes: tasmota %v, zigbee %v", hasIndex("tasmota_data"), hasIndex("zigbee_data"))
+	}
+
+	// ohne Index wird trotzdem geschrieben
+	cols := []string{"device_id", "timestamp_unix", "power"}
+	if ok, err := InsertReading(dbh, "tasmota_data", config.ConflictIgnore, cols, "plug", 4000, 6); !ok || err != nil {
+		t.Fatalf("insert without index: %v %v", ok, err)
+	}
+
+	dups, err := Duplicates(dbh)
+	if err != nil || len(dups) != 1 || dups[0] != (TableDuplicates{"tasmota_data", 1}) {
+		t.Fatalf("Duplicates = %v, %v", dups, err)
+	}
+
+	// NULL-Schlüssel sind keine Duplikate
+	removed, err := RemoveDuplicates(dbh)
+	if err != nil || len(removed) != 1 || removed[0].Rows != 1 {
+		t.Fatalf("RemoveDuplicates = %v, %v", removed, err)
+	}
+	var first int
+	if err := dbh.QueryRow(`SELECT MIN(power) FROM tasmota_data WHERE timestamp_unix = 1000`).Scan(&first); err != nil || first != 1 {
+		t.Fatalf("first row not kept: %d %v", first, err)
+	}
+	if n := count(); n != 5 || !hasIndex("tasmota_data") {
+		t.Fatalf("after dedup: %d rows, index %v", n, hasIndex("tasmota_data"))
+	}
+}
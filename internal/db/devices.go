@@ -0,0 +1,207 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// -------------------------------------------------------------------
+// Geräteregister (Tasmota, Solar, ...)
+// -------------------------------------------------------------------
+
+// Device ist ein Eintrag aus der Tabelle devices
+type Device struct {
+	Source       string          `json:"source"`
+	ID           string          `json:"device"`
+	Name         string          `json:"name"`
+	Room         string          `json:"room"`
+	Category     string          `json:"category"`
+	NominalPower *float64        `json:"nominal_power_w"`
+	FirstSeen    *time.Time      `json:"first_seen"`
+	LastSeen     *time.Time      `json:"last_seen"`
+	Info         json.RawMessage `json:"info,omitempty"`
+	State        json.RawMessage `json:"state,omitempty"`
+}
+
+// DeviceUpdate enthält die editierbaren Felder; nil bleibt unverändert,
+// ein leerer String löscht den Wert
+type DeviceUpdate struct {
+	Name         *string  `json:"name"`
+	Room         *string  `json:"room"`
+	Category     *string  `json:"category"`
+	NominalPower *float64 `json:"nominal_power_w"`
+}
+
+// seedDevices übernimmt beim ersten Start mit Geräteregister die Geräte aus
+// den vorhandenen Messdaten
+func seedDevices(db *sql.DB) error {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM devices`).Scan(&n); err != nil || n > 0 {
+		return err
+	}
+	_, err := db.Exec(`
+		INSERT OR IGNORE INTO devices (source, device_id, first_seen, last_seen)
+		SELECT 'tasmota', device_id, MIN(timestamp_unix), MAX(timestamp_unix)
+		FROM tasmota_data WHERE device_id IS NOT NULL GROUP BY device_id
+		UNION ALL
+		SELECT 'shelly', device_id, MIN(timestamp_unix), MAX(timestamp_unix)
+		FROM shelly_data WHERE device_id IS NOT NULL GROUP BY device_id
+		UNION ALL
+		SELECT 'zigbee', device_id, MIN(timestamp_unix), MAX(timestamp_unix)
+		FROM zigbee_data WHERE device_id IS NOT NULL GROUP BY device_id
+		UNION ALL
+		SELECT 'sml', meter_id, MIN(timestamp_unix), MAX(timestamp_unix)
+		FROM meter_data WHERE meter_id IS NOT NULL GROUP BY meter_id
+		UNION ALL
+		SELECT 'solar', device_id, MIN(timestamp_unix), MAX(timestamp_unix)
+		FROM solar_data WHERE device_id IS NOT NULL GROUP BY device_id
+	`)
+	if err != nil {
+		return fmt.Errorf("Fehler beim Übernehmen der Geräte: %w", err)
+	}
+	return nil
+}
+
+// TouchDevice legt ein Gerät beim ersten Empfang an und aktualisiert last_seen
+func TouchDevice(db *sql.DB, source, id string, ts time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO devices (source, device_id, first_seen, last_seen)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(source, device_id) DO UPDATE SET
+			first_seen = MIN(COALESCE(first_seen, excluded.first_seen), excluded.first_seen),
+			last_seen  = MAX(COALESCE(last_seen, excluded.last_seen), excluded.last_seen)
+	`, source, id, ts.Unix(), ts.Unix())
+	return err
+}
+
+// SetDeviceInfo führt JSON-Metadaten (z.B. Tasmota INFO1..3) mit den
+// vorhandenen zusammen
+func SetDeviceInfo(db *sql.DB, source, id string, info json.RawMessage) error {
+	_, err := db.Exec(`
+		INSERT INTO devices (source, device_id, info) VALUES (?, ?, json(?))
+		ON CONFLICT(source, device_id) DO UPDATE SET
+			info = json_patch(COALESCE(info, '{}'), excluded.info)
+	`, source, id, string(info))
+	return err
+}
+
+// SetDeviceState speichert den letzten Status (z.B. Tasmota STATE)
+func SetDeviceState(db *sql.DB, source, id string, state json.RawMessage) error {
+	_, err := db.Exec(`
+		INSERT INTO devices (source, device_id, state) VALUES (?, ?, json(?))
+		ON CONFLICT(source, device_id) DO UPDATE SET state = excluded.state
+	`, source, id, string(state))
+	return err
+}
+
+// UpdateDevice setzt Name, Raum, Kategorie und Nennleistung. Unbekannte
+// Geräte werden angelegt, damit sie vorab gepflegt werden können.
+func UpdateDevice(db *sql.DB, source, id string, u DeviceUpdate) (Device, error) {
+	if source == "" || id == "" {
+		return Device{}, fmt.Errorf("Quelle und Geräte-ID erforderlich")
+	}
+	if u.NominalPower != nil && *u.NominalPower < 0 {
+		return Device{}, fmt.Errorf("Nennleistung darf nicht negativ sein")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return Device{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO devices (source, device_id) VALUES (?, ?)`, source, id); err != nil {
+		return Device{}, err
+	}
+	set := func(col string, v any) error {
+		_, err := tx.Exec(`UPDATE devices SET `+col+` = ? WHERE source = ? AND device_id = ?`, v, source, id)
+		return err
+	}
+	for col, v := range map[string]*string{"friendly_name": u.Name, "room": u.Room, "category": u.Category} {
+		if v == nil {
+			continue
+		}
+		var val any = *v
+		if *v == "" {
+			val = nil
+		}
+		if err := set(col, val); err != nil {
+			return Device{}, err
+		}
+	}
+	if u.NominalPower != nil {
+		if err := set("nominal_power", *u.NominalPower); err != nil {
+			return Device{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Device{}, err
+	}
+
+	d, _, err := GetDevice(db, source, id)
+	return d, err
+}
+
+const deviceColumns = `source, device_id, COALESCE(friendly_name, ''), COALESCE(room, ''),
+	COALESCE(category, ''), nominal_power, first_seen, last_seen, info, state`
+
+func scanDevice(row interface{ Scan(...any) error }) (Device, error) {
+	var d Device
+	var power sql.NullFloat64
+	var first, last sql.NullInt64
+	var info, state sql.NullString
+	if err := row.Scan(&d.Source, &d.ID, &d.Name, &d.Room, &d.Category, &power, &first, &last, &info, &state); err != nil {
+		return Device{}, err
+	}
+	if power.Valid {
+		d.NominalPower = &power.Float64
+	}
+	if first.Valid {
+		t := time.Unix(first.Int64, 0)
+		d.FirstSeen = &t
+	}
+	if last.Valid {
+		t := time.Unix(last.Int64, 0)
+		d.LastSeen = &t
+	}
+	if info.Valid {
+		d.Info = json.RawMessage(info.String)
+	}
+	if state.Valid {
+		d.State = json.RawMessage(state.String)
+	}
+	return d, nil
+}
+
+// GetDevice liefert ein einzelnes Gerät
+func GetDevice(db *sql.DB, source, id string) (Device, bool, error) {
+	d, err := scanDevice(db.QueryRow(`SELECT `+deviceColumns+` FROM devices WHERE source = ? AND device_id = ?`, source, id))
+	if err == sql.ErrNoRows {
+		return Device{}, false, nil
+	}
+	if err != nil {
+		return Device{}, false, err
+	}
+	return d, true, nil
+}
+
+// Devices liefert alle Geräte sortiert nach Quelle und ID
+func Devices(db *sql.DB) ([]Device, error) {
+	rows, err := db.Query(`SELECT ` + deviceColumns + ` FROM devices ORDER BY source, device_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Device{}
+	for rows.Next() {
+		d, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func newSchemaDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dbh, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	dbh.SetMaxOpenConns(1)
+	if err := CreateSchema(dbh); err != nil {
+		t.Fatalf("CreateSchema: %v", err)
+	}
+	return dbh
+}
+
+func TestDeviceRegistry(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	t1 := time.Unix(1000, 0)
+	t2 := time.Unix(2000, 0)
+	for _, ts := range []time.Time{t2, t1} {
+		if err := TouchDevice(dbh, "tasmota", "plug1", ts); err != nil {
+			t.Fatalf("TouchDevice: %v", err)
+		}
+	}
+	if err := SetDeviceInfo(dbh, "tasmota", "plug1", json.RawMessage(`{"Info1":{"Module":"Gosund"}}`)); err != nil {
+		t.Fatalf("SetDeviceInfo: %v", err)
+	}
+	if err := SetDeviceInfo(dbh, "tasmota", "plug1", json.RawMessage(`{"Info2":{"IPAddress":"10.0.0.5"}}`)); err != nil {
+		t.Fatalf("SetDeviceInfo: %v", err)
+	}
+
+	name, room, power := "Waschmaschine", "Keller", 2000.0
+	d, err := UpdateDevice(dbh, "tasmota", "plug1", DeviceUpdate{Name: &name, Room: &room, NominalPower: &power})
+	if err != nil {
+		t.Fatalf("UpdateDevice: %v", err)
+	}
+	if d.Name != name || d.Room != room || d.NominalPower == nil || *d.NominalPower != power {
+		t.Fatalf("unexpected device: %+v", d)
+	}
+	if d.FirstSeen == nil || !d.FirstSeen.Equal(t1) || d.LastSeen == nil || !d.LastSeen.Equal(t2) {
+		t.Fatalf("unexpected first/last seen: %v %v", d.FirstSeen, d.LastSeen)
+	}
+	var info map[string]any
+	if err := json.Unmarshal(d.Info, &info); err != nil || len(info) != 2 {
+		t.Fatalf("INFO not merged: %s (%v)", d.Info, err)
+	}
+
+	// leerer String löscht, nil lässt unverändert
+	empty := ""
+	if d, err = UpdateDevice(dbh, "tasmota", "plug1", DeviceUpdate{Room: &empty}); err != nil || d.Room != "" || d.Name != name {
+		t.Fatalf("partial update: %+v (%v)", d, err)
+	}
+
+	if _, err := dbh.Exec(`INSERT INTO tasmota_data (device_id, timestamp_unix, power) VALUES ('plug1', 3000, 5)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	live, err := LatestDevicePower(dbh)
+	if err != nil || len(live) != 1 || live[0].Name != name {
+		t.Fatalf("friendly name not joined: %+v (%v)", live, err)
+	}
+}
+
+func TestSeedDevicesFromExistingData(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	if _, err := dbh.Exec(`
+		INSERT INTO tasmota_data (device_id, timestamp_unix, power) VALUES ('plug1', 100, 1), ('plug1', 300, 1);
+		INSERT INTO solar_data (device_id, timestamp_unix, metric, value) VALUES ('inv1', 200, 'power', 5);
+	`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := seedDevices(dbh); err != nil {
+		t.Fatalf("seedDevices: %v", err)
+	}
+
+	devices, err := Devices(dbh)
+	if err != nil || len(devices) != 2 {
+		t.Fatalf("expected 2 devices, got %+v (%v)", devices, err)
+	}
+	if d := devices[1]; d.Source != "tasmota" || d.FirstSeen.Unix() != 100 || d.LastSeen.Unix() != 300 {
+		t.Fatalf("unexpected seeded device: %+v", d)
+	}
+}
+
+func TestLatestDeviceValuesIncludeZigbeePlugs(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	for _, q := range []string{
+		`INSERT INTO tasmota_data (device_id, timestamp_unix, power, energy_total) VALUES ('plug1', 100, 5, 1.5), ('plug1', 200, 6, 1.6)`,
+		`INSERT INTO zigbee_data (device_id, timestamp_unix, power) VALUES ('Trockner', 150, 900)`,
+		`INSERT INTO zigbee_data (device_id, timestamp_unix, energy_total) VALUES ('Trockner', 160, 42)`,
+	} {
+		if _, err := dbh.Exec(q); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	power, err := LatestDevicePower(dbh)
+	if err != nil {
+		t.Fatalf("LatestDevicePower: %v", err)
+	}
+	if len(power) != 2 || power[0].Source != "tasmota" || power[0].Power != 6 ||
+		power[1].Source != "zigbee" || power[1].Device != "Trockner" || power[1].Power != 900 {
+		t.Fatalf("unexpected power: %+v", power)
+	}
+
+	energy, err := LatestDeviceEnergy(dbh)
+	if err != nil {
+		t.Fatalf("LatestDeviceEnergy: %v", err)
+	}
+	if len(energy) != 2 || energy[0].Total != 1.6 || energy[1].Total != 42 {
+		t.Fatalf("unexpected energy: %+v", energy)
+	}
+}
+
+func TestLatestDeviceValuesSumShellyChannels(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	// 3EM: Phasen melden zu unterschiedlichen Zeiten, Energie getrennt
+	for _, q := range []string{
+		`INSERT INTO shelly_data (device_id, channel, timestamp_unix, power) VALUES
+			('pro3em', 0, 100, 100), ('pro3em', 1, 100, 200), ('pro3em', 2, 100, 300),
+			('pro3em', 0, 200, 150)`,
+		`INSERT INTO shelly_data (device_id, channel, timestamp_unix, energy_total) VALUES
+			('pro3em', 0, 210, 10), ('pro3em', 1, 210, 20), ('pro3em', 2, 210, 30)`,
+	} {
+		if _, err := dbh.Exec(q); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	power, err := LatestDevicePower(dbh)
+	if err != nil {
+		t.Fatalf("LatestDevicePower: %v", err)
+	}
+	if len(power) != 1 || power[0].Source != "shelly" || power[0].Device != "pro3em" || power[0].Power != 650 {
+		t.Fatalf("unexpected power: %+v", power)
+	}
+
+	energy, err := LatestDeviceEnergy(dbh)
+	if err != nil {
+		t.Fatalf("LatestDeviceEnergy: %v", err)
+	}
+	if len(energy) != 1 || energy[0].Total != 60 || energy[0].Time.Unix() != 210 {
+		t.Fatalf("unexpected energy: %+v", energy)
+	}
+}
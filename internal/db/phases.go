@@ -0,0 +1,98 @@
+package db
+
+import (
+	"database/sql"
+	"math"
+	"time"
+)
+
+// -------------------------------------------------------------------
+// Drehstrom: Auswertung pro Phase und Schieflast
+// -------------------------------------------------------------------
+
+// PhaseStats fasst eine Phase im Zeitraum zusammen; nil = nicht geliefert
+type PhaseStats struct {
+	Phase      int      `json:"phase"`
+	AvgPower   *float64 `json:"avg_power_w"`
+	MaxPower   *float64 `json:"max_power_w"`
+	AvgVoltage *float64 `json:"avg_voltage_v"`
+	AvgCurrent *float64 `json:"avg_current_a"`
+}
+
+// PhaseReport ist die Phasenauswertung eines Zeitraums. Imbalance ist die
+// größte Abweichung einer Phase vom Mittel der drei Phasen in Prozent
+// (Definition nach NEMA), MaxSpread die größte gleichzeitige Differenz
+// zwischen stärkster und schwächster Phase.
+type PhaseReport struct {
+	From      time.Time    `json:"from"`
+	To        time.Time    `json:"to"`
+	Samples   int          `json:"samples"`
+	Phases    []PhaseStats `json:"phases"`
+	Imbalance *float64     `json:"imbalance_pct"`
+	MaxSpread *float64     `json:"max_spread_w"`
+}
+
+// PhaseImbalance wertet die Phasenleistungen im Bereich [from, to] aus
+func PhaseImbalance(db *sql.DB, from, to time.Time) (PhaseReport, error) {
+	r := PhaseReport{From: from, To: to}
+
+	var v [13]sql.NullFloat64
+	err := db.QueryRow(`
+		SELECT COUNT(*),
+		       AVG(power_l1), MAX(power_l1), AVG(voltage_l1), AVG(current_l1),
+		       AVG(power_l2), MAX(power_l2), AVG(voltage_l2), AVG(current_l2),
+		       AVG(power_l3), MAX(power_l3), AVG(voltage_l3), AVG(current_l3),
+		       MAX(MAX(power_l1, power_l2, power_l3) - MIN(power_l1, power_l2, power_l3))
+		FROM energy_data
+		WHERE timestamp_unix BETWEEN ? AND ?
+		  AND (power_l1 IS NOT NULL OR power_l2 IS NOT NULL OR power_l3 IS NOT NULL)
+	`, from.Unix(), to.Unix()).Scan(&r.Samples,
+		&v[0], &v[1], &v[2], &v[3],
+		&v[4], &v[5], &v[6], &v[7],
+		&v[8], &v[9], &v[10], &v[11],
+		&v[12])
+	if err != nil {
+		return r, err
+	}
+
+	ptr := func(n sql.NullFloat64) *float64 {
+		if !n.Valid {
+			return nil
+		}
+		return &n.Float64
+	}
+	r.Phases = make([]PhaseStats, 3)
+	for i := range r.Phases {
+		r.Phases[i] = PhaseStats{
+			Phase:      i + 1,
+			AvgPower:   ptr(v[4*i]),
+			MaxPower:   ptr(v[4*i+1]),
+			AvgVoltage: ptr(v[4*i+2]),
+			AvgCurrent: ptr(v[4*i+3]),
+		}
+	}
+	r.MaxSpread = ptr(v[12])
+	r.Imbalance = imbalance(r.Phases)
+	return r, nil
+}
+
+// imbalance: max. Abweichung vom Mittelwert / Mittelwert * 100
+func imbalance(phases []PhaseStats) *float64 {
+	var sum float64
+	for _, p := range phases {
+		if p.AvgPower == nil {
+			return nil
+		}
+		sum += *p.AvgPower
+	}
+	mean := sum / float64(len(phases))
+	if mean == 0 {
+		return nil
+	}
+	var dev float64
+	for _, p := range phases {
+		dev = math.Max(dev, math.Abs(*p.AvgPower-mean))
+	}
+	pct := dev / math.Abs(mean) * 100
+	return &pct
+}
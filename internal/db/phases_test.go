@@ -0,0 +1,56 @@
+package db
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPhaseImbalance(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	for i, p := range [][3]float64{{100, 200, 300}, {300, 200, 100}, {200, 200, 800}} {
+		_, err := dbh.Exec(`INSERT INTO energy_data (timestamp_unix, e_in, e_out, power, power_l1, power_l2, power_l3, voltage_l1)
+			VALUES (?, 1, 0, ?, ?, ?, ?, 230)`, 1000+i, p[0]+p[1]+p[2], p[0], p[1], p[2])
+		if err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	// einphasiger Datensatz zählt nicht mit
+	if _, err := dbh.Exec(`INSERT INTO energy_data (timestamp_unix, e_in, e_out, power) VALUES (1005, 1, 0, 50)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	r, err := PhaseImbalance(dbh, time.Unix(0, 0), time.Unix(2000, 0))
+	if err != nil {
+		t.Fatalf("PhaseImbalance: %v", err)
+	}
+	if r.Samples != 3 || len(r.Phases) != 3 {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	// Mittel: L1 200, L2 200, L3 400 -> Mittelwert 266.67, Abweichung 133.33 = 50 %
+	if *r.Phases[2].AvgPower != 400 || math.Abs(*r.Imbalance-50) > 1e-9 || *r.MaxSpread != 600 {
+		t.Fatalf("imbalance=%v spread=%v L3=%v", *r.Imbalance, *r.MaxSpread, *r.Phases[2].AvgPower)
+	}
+	if *r.Phases[0].AvgVoltage != 230 || r.Phases[1].AvgVoltage != nil {
+		t.Fatalf("voltage L1=%v L2=%v", r.Phases[0].AvgVoltage, r.Phases[1].AvgVoltage)
+	}
+
+	names, err := SeriesNames(dbh)
+	if err != nil {
+		t.Fatalf("SeriesNames: %v", err)
+	}
+	var hasL1, hasFreq bool
+	for _, n := range names {
+		hasL1 = hasL1 || n == "power_l1"
+		hasFreq = hasFreq || n == "frequency"
+	}
+	if !hasL1 || hasFreq {
+		t.Fatalf("series names: %v", names)
+	}
+	points, err := QuerySeries(dbh, "phase_imbalance", time.Unix(0, 0), time.Unix(2000, 0), time.Hour, time.UTC)
+	if err != nil || len(points) != 1 || math.Abs(points[0].Value-(200+200+600)/3.0) > 1e-9 {
+		t.Fatalf("phase_imbalance series: %v (%v)", points, err)
+	}
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+// -------------------------------------------------------------------
+// Quarantäne: abgelehnte Messwerte zur Prüfung
+// -------------------------------------------------------------------
+
+// Quarantined ist ein abgelehnter Messwert. Row enthält die vollständige
+// Zeile für target_table, damit sie später übernommen werden kann.
+type Quarantined struct {
+	ID       int64          `json:"id"`
+	Received time.Time      `json:"received"`
+	Source   string         `json:"source"`
+	Device   string         `json:"device"`
+	Table    string         `json:"table"`
+	Time     time.Time      `json:"time"`
+	Metric   string         `json:"metric"`
+	Value    *float64       `json:"value"`
+	Reason   string         `json:"reason"`
+	Row      map[string]any `json:"row"`
+}
+
+// ErrNotQuarantined meldet eine unbekannte Quarantäne-ID
+var ErrNotQuarantined = errors.New("kein Eintrag in der Quarantäne")
+
+// ReadingIdentity liefert die Gerätespalten einer Rohdatentabelle, also den
+// eindeutigen Schlüssel ohne Zeitstempel
+func ReadingIdentity(table string) []string {
+	var out []string
+	for _, k := range readingKeys[table] {
+		if k != "timestamp_unix" {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// StoredValue ist ein gespeicherter Messwert mit Zeitstempel (Unix)
+type StoredValue struct {
+	Value float64
+	TS    int64
+}
+
+// RecentValues liefert die letzten n gespeicherten Werte von column vor
+// before (Unix) für das Gerät identity (Werte zu ReadingIdentity), neueste zuerst
+func RecentValues(ex Execer, table, column string, identity []any, before int64, n int) ([]StoredValue, error) {
+	cols := ReadingIdentity(table)
+	if len(cols) != len(identity) || !slices.Contains(readingColumns(table), column) {
+		return nil, fmt.Errorf("ungültige Abfrage %s.%s", table, column)
+	}
+	where := []string{column + " IS NOT NULL", "timestamp_unix < ?"}
+	args := []any{before}
+	for i, c := range cols {
+		where = append(where, c+" = ?")
+		args = append(args, identity[i])
+	}
+	rows, err := ex.Query(fmt.Sprintf(`
+		SELECT %s, timestamp_unix FROM %s WHERE %s ORDER BY timestamp_unix DESC LIMIT ?
+	`, column, table, strings.Join(where, " AND ")), append(args, n)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StoredValue
+	for rows.Next() {
+		var v StoredValue
+		if err := rows.Scan(&v.Value, &v.TS); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// readingColumns sind die Messwert-Spalten, die RecentValues lesen darf
+func readingColumns(table string) []string {
+	switch table {
+	case "energy_data":
+		return []string{"e_in", "e_out", "power",
+			"power_l1", "power_l2", "power_l3", "voltage_l1", "voltage_l2", "voltage_l3",
+			"current_l1", "current_l2", "current_l3", "frequency",
+			"e_in_t1", "e_in_t2", "e_out_t1", "e_out_t2"}
+	case "meter_data":
+		return []string{"e_in", "e_out", "power"}
+	case "tasmota_data":
+		return []string{"power", "energy_total"}
+	case "shelly_data":
+		return []string{"power", "voltage", "current", "energy_total", "energy_returned"}
+	case "zigbee_data":
+		return []string{"power", "energy_total", "voltage", "current"}
+	case "solar_data":
+		return []string{"value"}
+	}
+	return nil
+}
+
+// AddQuarantine legt einen abgelehnten Messwert ab
+func AddQuarantine(ex Execer, q Quarantined) error {
+	row, err := json.Marshal(q.Row)
+	if err != nil {
+		return err
+	}
+	_, err = ex.Exec(`
+		INSERT INTO quarantine (received_unix, source, device_id, target_table, timestamp_unix, metric, value, reason, row_json)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, q.Received.Unix(), q.Source, q.Device, q.Table, q.Time.Unix(), q.Metric, q.Value, q.Reason, string(row))
+	return err
+}
+
+// QuarantineList liefert alle Einträge, älteste zuerst
+func QuarantineList(db *sql.DB) ([]Quarantined, error) {
+	rows, err := db.Query(`
+		SELECT id, received_unix, source, device_id, target_table, timestamp_unix, metric, value, reason, row_json
+		FROM quarantine ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Quarantined{}
+	for rows.Next() {
+		q, err := scanQuarantined(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, q)
+	}
+	return out, rows.Err()
+}
+
+func scanQuarantined(s interface{ Scan(...any) error }) (Quarantined, error) {
+	var (
+		q             Quarantined
+		received, ts  int64
+		value         sql.NullFloat64
+		device, table sql.NullString
+		row           string
+	)
+	if err := s.Scan(&q.ID, &received, &q.Source, &device, &table, &ts, &q.Metric, &value, &q.Reason, &row); err != nil {
+		return q, err
+	}
+	q.Received, q.Time = time.Unix(received, 0), time.Unix(ts, 0)
+	q.Device, q.Table = device.String, table.String
+	if value.Valid {
+		q.Value = &value.Float64
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(row)))
+	dec.UseNumber()
+	if err := dec.Decode(&q.Row); err != nil {
+		return q, fmt.Errorf("Quarantäne %d: %w", q.ID, err)
+	}
+	return q, nil
+}
+
+// AcceptQuarantined übernimmt den Eintrag in seine Tabelle (gesetzte Werte
+// überschreiben eine vorhandene Zeile) und entfernt ihn aus der Quarantäne
+func AcceptQuarantined(db *sql.DB, id int64) error {
+	q, err := scanQuarantined(db.QueryRow(`
+		SELECT id, received_unix, source, device_id, target_table, timestamp_unix, metric, value, reason, row_json
+		FROM quarantine WHERE id = ?
+	`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotQuarantined
+	}
+	if err != nil {
+		return err
+	}
+
+	columns := slices.Sorted(maps.Keys(q.Row))
+	args := make([]any, len(columns))
+	for i, c := range columns {
+		args[i] = jsonValue(q.Row[c])
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := InsertReading(tx, q.Table, config.ConflictMerge, columns, args...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM quarantine WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DiscardQuarantined löscht den Eintrag endgültig
+func DiscardQuarantined(db *sql.DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM quarantine WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotQuarantined
+	}
+	return nil
+}
+
+// jsonValue macht aus json.Number wieder Ganz- bzw. Gleitkommazahlen
+func jsonValue(v any) any {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
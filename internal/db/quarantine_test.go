@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuarantineAcceptAndDiscard(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	v := 5000.0
+	for _, ts := range []int64{1000, 2000} {
+		err := AddQuarantine(dbh, Quarantined{
+			Received: time.Unix(ts, 0),
+			Source:   "tasmota",
+			Device:   "plug",
+			Table:    "tasmota_data",
+			Time:     time.Unix(ts, 0),
+			Metric:   "energy_total",
+			Value:    &v,
+			Reason:   "energy_total ändert sich zu schnell",
+			Row:      map[string]any{"device_id": "plug", "timestamp_unix": ts, "power": 12.5, "energy_total": v},
+		})
+		if err != nil {
+			t.Fatalf("AddQuarantine: %v", err)
+		}
+	}
+
+	list, err := QuarantineList(dbh)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("list = %+v %v", list, err)
+	}
+	if q := list[0]; q.Device != "plug" || q.Table != "tasmota_data" || *q.Value != v || !q.Time.Equal(time.Unix(1000, 0)) {
+		t.Fatalf("entry = %+v", q)
+	}
+
+	if err := AcceptQuarantined(dbh, list[0].ID); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if err := DiscardQuarantined(dbh, list[1].ID); err != nil {
+		t.Fatalf("discard: %v", err)
+	}
+	if err := DiscardQuarantined(dbh, list[1].ID); !errors.Is(err, ErrNotQuarantined) {
+		t.Fatalf("discard twice: %v", err)
+	}
+
+	var ts int64
+	var energy float64
+	if err := dbh.QueryRow(`SELECT timestamp_unix, energy_total FROM tasmota_data WHERE device_id = 'plug'`).Scan(&ts, &energy); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if ts != 1000 || energy != v {
+		t.Fatalf("accepted row = %d %v", ts, energy)
+	}
+
+	recent, err := RecentValues(dbh, "tasmota_data", "energy_total", []any{"plug"}, 1500, 5)
+	if err != nil || len(recent) != 1 || recent[0] != (StoredValue{v, 1000}) {
+		t.Fatalf("RecentValues = %v %v", recent, err)
+	}
+	if _, err := RecentValues(dbh, "tasmota_data", "id; DROP TABLE x", []any{"plug"}, 1500, 5); err == nil {
+		t.Fatal("expected error for unknown column")
+	}
+
+	if list, _ := QuarantineList(dbh); len(list) != 0 {
+		t.Fatalf("quarantine not empty: %+v", list)
+	}
+}
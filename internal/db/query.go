@@ -0,0 +1,312 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// -------------------------------------------------------------------
+// Lesezugriffe für HTTP-API und Dashboard
+// -------------------------------------------------------------------
+
+// PowerPoint ist ein einzelner Leistungswert
+type PowerPoint struct {
+	Time  time.Time `json:"time"`
+	Power float64   `json:"power"`
+}
+
+// DailyConsumption entspricht einer Zeile aus daily_energy
+// Complete ist false, wenn der Zeitraum Lücken in energy_data enthält.
+type DailyConsumption struct {
+	Day         string  `json:"day"`
+	Consumption float64 `json:"consumption"`
+	Complete    bool    `json:"complete"`
+}
+
+// MonthlyCost entspricht einer Zeile aus monthly_energy_cost
+type MonthlyCost struct {
+	Month       string  `json:"month"`
+	Consumption float64 `json:"consumption"`
+	Cost        float64 `json:"cost"`
+	Complete    bool    `json:"complete"`
+}
+
+// YearlyCost ist eine Zeile aus yearly_energy_cost (laufendes Jahr)
+type YearlyCost struct {
+	Consumption float64 `json:"consumption"`
+	Cost        float64 `json:"cost"`
+	Complete    bool    `json:"complete"`
+}
+
+// DevicePower ist der letzte Leistungswert eines Geräts
+type DevicePower struct {
+	Source string    `json:"source"`
+	Device string    `json:"device"`
+	Name   string    `json:"name,omitempty"`
+	Time   time.Time `json:"time"`
+	Power  float64   `json:"power"`
+}
+
+// LatestReading liefert den jüngsten Wattwaechter-Datensatz
+func LatestReading(db *sql.DB) (PowerPoint, bool, error) {
+	var ts int64
+	var p float64
+	err := db.QueryRow(`
+		SELECT timestamp_unix, power
+		FROM energy_data
+		ORDER BY timestamp_unix DESC
+		LIMIT 1
+	`).Scan(&ts, &p)
+	if err == sql.ErrNoRows {
+		return PowerPoint{}, false, nil
+	}
+	if err != nil {
+		return PowerPoint{}, false, err
+	}
+	return PowerPoint{Time: time.Unix(ts, 0), Power: p}, true, nil
+}
+
+// PowerSince liefert die Leistungswerte ab since (aufsteigend sortiert)
+func PowerSince(db *sql.DB, since time.Time) ([]PowerPoint, error) {
+	rows, err := db.Query(`
+		SELECT timestamp_unix, power
+		FROM energy_data
+		WHERE timestamp_unix >= ?
+		ORDER BY timestamp_unix
+	`, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []PowerPoint{}
+	for rows.Next() {
+		var ts int64
+		var p float64
+		if err := rows.Scan(&ts, &p); err != nil {
+			return nil, err
+		}
+		out = append(out, PowerPoint{Time: time.Unix(ts, 0), Power: p})
+	}
+	return out, rows.Err()
+}
+
+// plugData vereint die Steckdosen-Quellen (Tasmota, Zigbee2MQTT, Shelly) für
+// die Geräteabfragen. Shelly liefert eine Zeile pro Gerät: die Summe der
+// jeweils letzten Werte aller Kanäle bzw. Phasen, die nicht gleichzeitig
+// melden.
+const plugData = `
+	SELECT 'tasmota' AS source, device_id, timestamp_unix, power, energy_total FROM tasmota_data
+	UNION ALL
+	SELECT 'zigbee', device_id, timestamp_unix, power, energy_total FROM zigbee_data
+	UNION ALL
+	SELECT 'shelly', device_id, MAX(timestamp_unix), SUM(power), SUM(energy_total) FROM (
+		SELECT device_id, timestamp_unix,
+		       FIRST_VALUE(power) OVER (PARTITION BY device_id, channel ORDER BY power IS NULL, timestamp_unix DESC) AS power,
+		       FIRST_VALUE(energy_total) OVER (PARTITION BY device_id, channel ORDER BY energy_total IS NULL, timestamp_unix DESC) AS energy_total,
+		       ROW_NUMBER() OVER (PARTITION BY device_id, channel ORDER BY timestamp_unix DESC) AS rn
+		FROM shelly_data
+	) WHERE rn = 1
+	GROUP BY device_id`
+
+// LatestDevicePower liefert den letzten Leistungswert pro Steckdose
+func LatestDevicePower(db *sql.DB) ([]DevicePower, error) {
+	rows, err := db.Query(`
+		SELECT t.source, t.device_id, COALESCE(d.friendly_name, ''), t.timestamp_unix, t.power
+		FROM (
+			SELECT source, device_id, timestamp_unix, power,
+			       ROW_NUMBER() OVER (PARTITION BY source, device_id ORDER BY timestamp_unix DESC) AS rn
+			FROM (` + plugData + `)
+			WHERE power IS NOT NULL
+		) t
+		LEFT JOIN devices d ON d.source = t.source AND d.device_id = t.device_id
+		WHERE t.rn = 1
+		ORDER BY t.source, t.device_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []DevicePower{}
+	for rows.Next() {
+		var d DevicePower
+		var ts int64
+		if err := rows.Scan(&d.Source, &d.Device, &d.Name, &ts, &d.Power); err != nil {
+			return nil, err
+		}
+		d.Time = time.Unix(ts, 0)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// DailyConsumptions liefert die letzten n Tage aus daily_energy
+func DailyConsumptions(db *sql.DB, n int) ([]DailyConsumption, error) {
+	rows, err := db.Query(`
+		SELECT day, daily_consumption, complete
+		FROM (SELECT day, daily_consumption, complete FROM daily_energy ORDER BY day DESC LIMIT ?)
+		ORDER BY day
+	`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []DailyConsumption{}
+	for rows.Next() {
+		var d DailyConsumption
+		if err := rows.Scan(&d.Day, &d.Consumption, &d.Complete); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// MonthlyCosts liefert alle Monate aus monthly_energy_cost
+func MonthlyCosts(db *sql.DB) ([]MonthlyCost, error) {
+	rows, err := db.Query(`
+		SELECT month, monthly_consumption, monthly_cost, complete
+		FROM monthly_energy_cost
+		ORDER BY month
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []MonthlyCost{}
+	for rows.Next() {
+		var m MonthlyCost
+		if err := rows.Scan(&m.Month, &m.Consumption, &m.Cost, &m.Complete); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// CurrentYearCost liefert die Summe des Jahres von now. Wie in
+// CurrentSummary muss now in [time] timezone liegen; die View
+// yearly_energy_cost_current rechnet mit der SQLite-Uhr (UTC).
+func CurrentYearCost(db *sql.DB, now time.Time) (YearlyCost, error) {
+	var y YearlyCost
+	err := db.QueryRow(`
+		SELECT yearly_consumption, yearly_cost, complete
+		FROM yearly_energy_cost
+		WHERE year = ?
+	`, now.Year()).Scan(&y.Consumption, &y.Cost, &y.Complete)
+	if err == sql.ErrNoRows {
+		return YearlyCost{}, nil
+	}
+	return y, err
+}
+
+// Summary fasst die aktuellen Perioden zusammen (Tag, Monat, Jahr, Tarif)
+type Summary struct {
+	Day              string  `json:"day"`
+	DayConsumption   float64 `json:"day_consumption_kwh"`
+	Month            string  `json:"month"`
+	MonthConsumption float64 `json:"month_consumption_kwh"`
+	MonthCost        float64 `json:"month_cost"`
+	Year             int     `json:"year"`
+	YearConsumption  float64 `json:"year_consumption_kwh"`
+	YearCost         float64 `json:"year_cost"`
+	PerKWh           float64 `json:"per_kwh"`
+}
+
+// CurrentSummary liest die Aggregate für den Zeitpunkt now. Tag, Monat und
+// Jahr gelten in der Zone von now, die zu [time] timezone passen muss.
+func CurrentSummary(db *sql.DB, now time.Time, fallbackPerKWh float64) (Summary, error) {
+	s := Summary{
+		Day:    now.Format("2006-01-02"),
+		Month:  now.Format("2006-01"),
+		Year:   now.Year(),
+		PerKWh: Tariff(db, fallbackPerKWh),
+	}
+
+	err := db.QueryRow(`SELECT daily_consumption FROM daily_energy_raw WHERE day = ?`, s.Day).Scan(&s.DayConsumption)
+	if err != nil && err != sql.ErrNoRows {
+		return s, err
+	}
+	err = db.QueryRow(`SELECT consumption, cost FROM monthly_energy_cost_raw WHERE month = ?`, s.Month).Scan(&s.MonthConsumption, &s.MonthCost)
+	if err != nil && err != sql.ErrNoRows {
+		return s, err
+	}
+	err = db.QueryRow(`SELECT consumption, cost FROM yearly_energy_cost_current_raw WHERE year = ?`, s.Year).Scan(&s.YearConsumption, &s.YearCost)
+	if err != nil && err != sql.ErrNoRows {
+		return s, err
+	}
+	return s, nil
+}
+
+// Meter ist der letzte Zählerstand des Wattwaechters
+type Meter struct {
+	Time      time.Time `json:"time"`
+	EnergyIn  float64   `json:"e_in"`
+	EnergyOut float64   `json:"e_out"`
+}
+
+// LatestMeter liefert den letzten Zählerstand (Bezug und Einspeisung)
+func LatestMeter(db *sql.DB) (Meter, bool, error) {
+	var m Meter
+	var ts int64
+	err := db.QueryRow(`
+		SELECT timestamp_unix, e_in, e_out
+		FROM energy_data
+		WHERE timestamp_unix > 0
+		ORDER BY timestamp_unix DESC
+		LIMIT 1
+	`).Scan(&ts, &m.EnergyIn, &m.EnergyOut)
+	if err == sql.ErrNoRows {
+		return Meter{}, false, nil
+	}
+	if err != nil {
+		return Meter{}, false, err
+	}
+	m.Time = time.Unix(ts, 0)
+	return m, true, nil
+}
+
+// DeviceEnergy ist der letzte Energiezähler einer Steckdose
+type DeviceEnergy struct {
+	Source string    `json:"source"`
+	Device string    `json:"device"`
+	Name   string    `json:"name,omitempty"`
+	Time   time.Time `json:"time"`
+	Total  float64   `json:"total_kwh"`
+}
+
+// LatestDeviceEnergy liefert pro Steckdose den letzten Energiezählerstand
+// (Tasmota ENERGY.Total, Zigbee2MQTT energy, Shelly Summe der Kanäle)
+func LatestDeviceEnergy(db *sql.DB) ([]DeviceEnergy, error) {
+	rows, err := db.Query(`
+		SELECT t.source, t.device_id, COALESCE(d.friendly_name, ''), t.timestamp_unix, t.energy_total
+		FROM (
+			SELECT source, device_id, timestamp_unix, energy_total,
+			       ROW_NUMBER() OVER (PARTITION BY source, device_id ORDER BY timestamp_unix DESC) AS rn
+			FROM (` + plugData + `)
+			WHERE energy_total IS NOT NULL
+		) t
+		LEFT JOIN devices d ON d.source = t.source AND d.device_id = t.device_id
+		WHERE t.rn = 1
+		ORDER BY t.source, t.device_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []DeviceEnergy{}
+	for rows.Next() {
+		var d DeviceEnergy
+		var ts int64
+		if err := rows.Scan(&d.Source, &d.Device, &d.Name, &ts, &d.Total); err != nil {
+			return nil, err
+		}
+		d.Time = time.Unix(ts, 0)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
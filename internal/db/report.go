@@ -0,0 +1,88 @@
+package db
+
+import "database/sql"
+
+// -------------------------------------------------------------------
+// Vergleiche für mqttlogger report (Vormonat, Vorjahr, 12 Monate)
+// -------------------------------------------------------------------
+
+// MonthComparison entspricht einer Zeile aus monthly_energy_comparison.
+// Fehlt der Vergleichsmonat, bleiben Wert und Δ nil.
+type MonthComparison struct {
+	Month                 string   `json:"month"`
+	Consumption           float64  `json:"consumption"`
+	Cost                  float64  `json:"cost"`
+	Complete              bool     `json:"complete"`
+	PrevMonthConsumption  *float64 `json:"prev_month_consumption"`
+	MoMDeltaPct           *float64 `json:"mom_delta_pct"`
+	LastYearConsumption   *float64 `json:"last_year_consumption"`
+	YoYDeltaPct           *float64 `json:"yoy_delta_pct"`
+	Rolling12mConsumption float64  `json:"rolling_12m_consumption"`
+	Rolling12mCost        float64  `json:"rolling_12m_cost"`
+}
+
+// YearComparison entspricht einer Zeile aus yearly_energy_comparison
+type YearComparison struct {
+	Year                int      `json:"year"`
+	Consumption         float64  `json:"consumption"`
+	Cost                float64  `json:"cost"`
+	Complete            bool     `json:"complete"`
+	PrevYearConsumption *float64 `json:"prev_year_consumption"`
+	PrevYearCost        *float64 `json:"prev_year_cost"`
+	YoYDeltaPct         *float64 `json:"yoy_delta_pct"`
+}
+
+// MonthComparisons liefert die letzten months Monate, älteste zuerst
+func MonthComparisons(db *sql.DB, months int) ([]MonthComparison, error) {
+	rows, err := db.Query(`
+		SELECT * FROM (
+			SELECT month, monthly_consumption, monthly_cost, complete,
+			       prev_month_consumption, mom_delta_pct, last_year_consumption, yoy_delta_pct,
+			       rolling_12m_consumption, rolling_12m_cost
+			FROM monthly_energy_comparison
+			ORDER BY month DESC
+			LIMIT ?
+		) ORDER BY month
+	`, months)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []MonthComparison{}
+	for rows.Next() {
+		var m MonthComparison
+		if err := rows.Scan(&m.Month, &m.Consumption, &m.Cost, &m.Complete,
+			&m.PrevMonthConsumption, &m.MoMDeltaPct, &m.LastYearConsumption, &m.YoYDeltaPct,
+			&m.Rolling12mConsumption, &m.Rolling12mCost); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// YearComparisons liefert alle Jahre mit Vergleich zum Vorjahr
+func YearComparisons(db *sql.DB) ([]YearComparison, error) {
+	rows, err := db.Query(`
+		SELECT year, yearly_consumption, yearly_cost, complete,
+		       prev_year_consumption, prev_year_cost, yoy_delta_pct
+		FROM yearly_energy_comparison
+		ORDER BY year
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []YearComparison{}
+	for rows.Next() {
+		var y YearComparison
+		if err := rows.Scan(&y.Year, &y.Consumption, &y.Cost, &y.Complete,
+			&y.PrevYearConsumption, &y.PrevYearCost, &y.YoYDeltaPct); err != nil {
+			return nil, err
+		}
+		out = append(out, y)
+	}
+	return out, rows.Err()
+}
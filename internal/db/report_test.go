@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestComparisonViews(t *testing.T) {
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	// Jan 2024 bis Mär 2025 ohne Mai 2024, Verbrauch 100 + Monatsindex
+	for i := 0; i < 15; i++ {
+		month := fmt.Sprintf("%d-%02d", 2024+i/12, i%12+1)
+		if month == "2024-05" {
+			continue
+		}
+		c := 100 + float64(i)
+		if _, err := dbh.Exec(`INSERT INTO monthly_energy_cost_raw (month, consumption, cost) VALUES (?, ?, ?)`, month, c, c/2); err != nil {
+			t.Fatalf("insert month: %v", err)
+		}
+	}
+	for _, y := range []struct {
+		year        int
+		consumption float64
+	}{{2023, 0}, {2024, 1200}, {2025, 900}} {
+		if _, err := dbh.Exec(`INSERT INTO yearly_energy_cost_current_raw (year, consumption, cost) VALUES (?, ?, ?)`, y.year, y.consumption, y.consumption/2); err != nil {
+			t.Fatalf("insert year: %v", err)
+		}
+	}
+
+	months, err := MonthComparisons(dbh, 12)
+	if err != nil || len(months) != 12 {
+		t.Fatalf("months = %d, %v", len(months), err)
+	}
+	last := months[11]
+	if last.Month != "2025-03" || *last.PrevMonthConsumption != 113 || *last.LastYearConsumption != 102 {
+		t.Fatalf("2025-03 = %+v", last)
+	}
+	if *last.MoMDeltaPct != 0.9 || *last.YoYDeltaPct != 11.8 {
+		t.Errorf("deltas = %v / %v", *last.MoMDeltaPct, *last.YoYDeltaPct)
+	}
+	// Apr 2024 bis Mär 2025 ohne Mai: 103..114 − 104
+	if last.Rolling12mConsumption != 1302-104 || last.Rolling12mCost != (1302-104)/2.0 {
+		t.Errorf("rolling = %v / %v", last.Rolling12mConsumption, last.Rolling12mCost)
+	}
+	for _, m := range months {
+		if m.Month == "2024-06" && (m.PrevMonthConsumption != nil || m.MoMDeltaPct != nil) {
+			t.Errorf("2024-06 without previous month = %+v", m)
+		}
+	}
+
+	years, err := YearComparisons(dbh)
+	if err != nil || len(years) != 3 {
+		t.Fatalf("years = %+v, %v", years, err)
+	}
+	if years[0].PrevYearConsumption != nil {
+		t.Errorf("2023 = %+v", years[0])
+	}
+	// Vorjahr 0: kein Δ statt Division durch null
+	if years[1].YoYDeltaPct != nil || *years[1].PrevYearConsumption != 0 {
+		t.Errorf("2024 = %+v", years[1])
+	}
+	if *years[2].YoYDeltaPct != -25 || *years[2].PrevYearCost != 600 {
+		t.Errorf("2025 = %+v", years[2])
+	}
+}
+
+func TestCurrentYearUsesConfiguredZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata: %v", err)
+	}
+	dbh := newSchemaDB(t)
+	defer dbh.Close()
+
+	for year, c := range map[int]float64{2025: 3000, 2026: 0.4} {
+		if _, err := dbh.Exec(`INSERT INTO yearly_energy_cost_current_raw (year, consumption, cost) VALUES (?, ?, ?)`, year, c, c/2); err != nil {
+			t.Fatalf("insert year: %v", err)
+		}
+	}
+
+	// 00:30 in Berlin, in UTC noch 2025
+	now := time.Date(2026, 1, 1, 0, 30, 0, 0, berlin)
+	y, err := CurrentYearCost(dbh, now)
+	if err != nil || y.Consumption != 0.4 {
+		t.Fatalf("current year = %+v, %v", y, err)
+	}
+	tbl, err := QueryTable(dbh, "yearly_energy_cost_current", now)
+	if err != nil || len(tbl.Rows) != 1 || tbl.Rows[0][0] != 0.4 {
+		t.Fatalf("table = %+v, %v", tbl, err)
+	}
+}
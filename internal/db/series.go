@@ -0,0 +1,369 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -------------------------------------------------------------------
+// Zeitreihen & Tabellen für externe Abfragen (z.B. Grafana)
+// -------------------------------------------------------------------
+
+// SeriesPoint ist ein Punkt einer (ggf. verdichteten) Zeitreihe
+type SeriesPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// Column beschreibt eine Tabellenspalte
+type Column struct {
+	Text string `json:"text"`
+	Type string `json:"type"` // string, number, time
+}
+
+// Table ist ein generisches Abfrageergebnis
+type Table struct {
+	Columns []Column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// rawSeries beschreibt Zeitreihen direkt aus den Rohdaten-Tabellen.
+// agg ist die Verdichtung pro Intervall: Zählerstände per MAX, Leistung per AVG.
+type rawSeries struct {
+	table string
+	col   string
+	agg   string
+	where string
+}
+
+var fixedSeries = map[string]rawSeries{
+	"power": {table: "energy_data", col: "power", agg: "AVG"},
+	"e_in":  {table: "energy_data", col: "e_in", agg: "MAX"},
+	"e_out": {table: "energy_data", col: "e_out", agg: "MAX"},
+}
+
+// Drehstrom- und Tarifreihen; nur vorhanden, wenn der Lesekopf sie liefert
+var phaseSeries = map[string]rawSeries{
+	"power_l1":   {table: "energy_data", col: "power_l1", agg: "AVG", where: "power_l1 IS NOT NULL"},
+	"power_l2":   {table: "energy_data", col: "power_l2", agg: "AVG", where: "power_l2 IS NOT NULL"},
+	"power_l3":   {table: "energy_data", col: "power_l3", agg: "AVG", where: "power_l3 IS NOT NULL"},
+	"voltage_l1": {table: "energy_data", col: "voltage_l1", agg: "AVG", where: "voltage_l1 IS NOT NULL"},
+	"voltage_l2": {table: "energy_data", col: "voltage_l2", agg: "AVG", where: "voltage_l2 IS NOT NULL"},
+	"voltage_l3": {table: "energy_data", col: "voltage_l3", agg: "AVG", where: "voltage_l3 IS NOT NULL"},
+	"current_l1": {table: "energy_data", col: "current_l1", agg: "AVG", where: "current_l1 IS NOT NULL"},
+	"current_l2": {table: "energy_data", col: "current_l2", agg: "AVG", where: "current_l2 IS NOT NULL"},
+	"current_l3": {table: "energy_data", col: "current_l3", agg: "AVG", where: "current_l3 IS NOT NULL"},
+	"frequency":  {table: "energy_data", col: "frequency", agg: "AVG", where: "frequency IS NOT NULL"},
+	"e_in_t1":    {table: "energy_data", col: "e_in_t1", agg: "MAX", where: "e_in_t1 IS NOT NULL"},
+	"e_in_t2":    {table: "energy_data", col: "e_in_t2", agg: "MAX", where: "e_in_t2 IS NOT NULL"},
+	"e_out_t1":   {table: "energy_data", col: "e_out_t1", agg: "MAX", where: "e_out_t1 IS NOT NULL"},
+	"e_out_t2":   {table: "energy_data", col: "e_out_t2", agg: "MAX", where: "e_out_t2 IS NOT NULL"},
+	// Spreizung zwischen stärkster und schwächster Phase in W
+	"phase_imbalance": {table: "energy_data", col: "MAX(power_l1, power_l2, power_l3) - MIN(power_l1, power_l2, power_l3)", agg: "AVG",
+		where: "power_l1 IS NOT NULL AND power_l2 IS NOT NULL AND power_l3 IS NOT NULL"},
+}
+
+// Aggregat-Zeitreihen aus den Views (Schlüssel = Periodenbeginn)
+var periodSeries = map[string]struct {
+	query  string
+	layout string
+}{
+	"daily_consumption":   {`SELECT day, daily_consumption FROM daily_energy`, "2006-01-02"},
+	"monthly_consumption": {`SELECT month, monthly_consumption FROM monthly_energy_cost`, "2006-01"},
+	"monthly_cost":        {`SELECT month, monthly_cost FROM monthly_energy_cost`, "2006-01"},
+}
+
+// Tabellen, die als Ganzes abgefragt werden können
+var tables = map[string]string{
+	"daily_energy":               `SELECT day, daily_consumption FROM daily_energy ORDER BY day`,
+	"weekly_energy":              `SELECT week, week_start, week_end, weekly_consumption FROM weekly_energy ORDER BY week`,
+	"monthly_energy_cost":        `SELECT month, monthly_consumption, monthly_cost FROM monthly_energy_cost ORDER BY month`,
+	"yearly_energy_cost_current": `SELECT yearly_consumption AS total_consumption, yearly_cost AS total_cost FROM yearly_energy_cost WHERE year = ?`,
+	"yearly_energy_cost":         `SELECT year, yearly_consumption, yearly_cost FROM yearly_energy_cost ORDER BY year`,
+	"monthly_energy_comparison": `SELECT month, monthly_consumption, prev_month_consumption, mom_delta_pct,
+		last_year_consumption, yoy_delta_pct, rolling_12m_consumption, rolling_12m_cost
+		FROM monthly_energy_comparison ORDER BY month`,
+	"yearly_energy_comparison": `SELECT year, yearly_consumption, prev_year_consumption, yoy_delta_pct, yearly_cost, prev_year_cost
+		FROM yearly_energy_comparison ORDER BY year`,
+}
+
+// SeriesNames liefert alle abfragbaren Zeitreihen inkl. Geräte-Reihen
+func SeriesNames(db *sql.DB) ([]string, error) {
+	names := []string{"power", "e_in", "e_out", "daily_consumption", "monthly_consumption", "monthly_cost"}
+
+	for _, name := range slices.Sorted(maps.Keys(phaseSeries)) {
+		s := phaseSeries[name]
+		var found int
+		err := db.QueryRow(`SELECT COUNT(*) FROM (SELECT 1 FROM energy_data WHERE ` + s.where + ` LIMIT 1)`).Scan(&found)
+		if err != nil {
+			return nil, err
+		}
+		if found > 0 {
+			names = append(names, name)
+		}
+	}
+
+	rows, err := db.Query(`SELECT DISTINCT device_id FROM tasmota_data ORDER BY device_id`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var dev string
+		if err := rows.Scan(&dev); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, "tasmota/"+dev+"/power")
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT DISTINCT device_id, channel FROM shelly_data ORDER BY device_id, channel`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var dev string
+		var ch int
+		if err := rows.Scan(&dev, &ch); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, m := range shellyMetrics {
+			names = append(names, fmt.Sprintf("shelly/%s/%d/%s", dev, ch, m))
+		}
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT DISTINCT meter_id FROM meter_data ORDER BY meter_id`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var meter string
+		if err := rows.Scan(&meter); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, m := range []string{"power", "e_in", "e_out"} {
+			names = append(names, "meter/"+meter+"/"+m)
+		}
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT DISTINCT device_id FROM zigbee_data ORDER BY device_id`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var dev string
+		if err := rows.Scan(&dev); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, m := range zigbeeMetrics {
+			names = append(names, "zigbee/"+dev+"/"+m)
+		}
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT DISTINCT device_id, metric FROM solar_data ORDER BY device_id, metric`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dev, metric string
+		if err := rows.Scan(&dev, &metric); err != nil {
+			return nil, err
+		}
+		names = append(names, "solar/"+dev+"/"+metric)
+	}
+	return names, rows.Err()
+}
+
+// textColumns sind die Periodenschlüssel und Datumsangaben der Tabellen
+var textColumns = map[string]bool{"day": true, "week": true, "week_start": true, "week_end": true, "month": true}
+
+// TableNames liefert alle abfragbaren Tabellen
+func TableNames() []string {
+	return []string{"daily_energy", "weekly_energy", "monthly_energy_cost", "yearly_energy_cost_current",
+		"yearly_energy_cost", "monthly_energy_comparison", "yearly_energy_comparison"}
+}
+
+// Spalten von shelly_data, die als Zeitreihe abfragbar sind
+var shellyMetrics = []string{"power", "voltage", "current", "energy_total", "energy_returned"}
+
+// Spalten von zigbee_data, die als Zeitreihe abfragbar sind
+var zigbeeMetrics = []string{"power", "energy_total", "voltage", "current"}
+
+// resolveSeries löst einen Namen auf. Geräte-Reihen folgen dem Topic-Schema
+// "meter/<zähler>/<power|e_in|e_out>", "tasmota/<device>/power",
+// "shelly/<device>/<kanal>/<metric>",
+// "zigbee/<device>/<metric>" bzw. "solar/<device>/<metric>".
+func resolveSeries(name string) (rawSeries, []any, bool) {
+	if s, ok := fixedSeries[name]; ok {
+		return s, nil, true
+	}
+	if s, ok := phaseSeries[name]; ok {
+		return s, nil, true
+	}
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) != 3 || parts[1] == "" {
+		return rawSeries{}, nil, false
+	}
+	switch {
+	case parts[0] == "meter":
+		s, ok := fixedSeries[parts[2]]
+		if !ok {
+			return rawSeries{}, nil, false
+		}
+		return rawSeries{table: "meter_data", col: s.col, agg: s.agg, where: "meter_id = ? AND " + s.col + " IS NOT NULL"}, []any{parts[1]}, true
+	case parts[0] == "tasmota" && parts[2] == "power":
+		return rawSeries{table: "tasmota_data", col: "power", agg: "AVG", where: "device_id = ?"}, []any{parts[1]}, true
+	case parts[0] == "shelly":
+		ch, metric, ok := strings.Cut(parts[2], "/")
+		n, err := strconv.Atoi(ch)
+		if !ok || err != nil || !slices.Contains(shellyMetrics, metric) {
+			return rawSeries{}, nil, false
+		}
+		agg := "AVG"
+		if strings.HasPrefix(metric, "energy_") {
+			agg = "MAX"
+		}
+		return rawSeries{table: "shelly_data", col: metric, agg: agg, where: "device_id = ? AND channel = ? AND " + metric + " IS NOT NULL"}, []any{parts[1], n}, true
+	case parts[0] == "zigbee" && slices.Contains(zigbeeMetrics, parts[2]):
+		agg := "AVG"
+		if parts[2] == "energy_total" {
+			agg = "MAX"
+		}
+		return rawSeries{table: "zigbee_data", col: parts[2], agg: agg, where: "device_id = ? AND " + parts[2] + " IS NOT NULL"}, []any{parts[1]}, true
+	case parts[0] == "solar":
+		return rawSeries{table: "solar_data", col: "value", agg: "AVG", where: "device_id = ? AND metric = ?"}, []any{parts[1], parts[2]}, true
+	}
+	return rawSeries{}, nil, false
+}
+
+// QuerySeries liefert eine Zeitreihe im Bereich [from, to], verdichtet auf step.
+// Perioden der Aggregate beginnen um 00:00 in loc ([time] timezone).
+func QuerySeries(db *sql.DB, name string, from, to time.Time, step time.Duration, loc *time.Location) ([]SeriesPoint, error) {
+	if p, ok := periodSeries[name]; ok {
+		return queryPeriodSeries(db, p.query, p.layout, from, to, loc)
+	}
+
+	s, filterArgs, ok := resolveSeries(name)
+	if !ok {
+		return nil, fmt.Errorf("unbekannte Zeitreihe: %s", name)
+	}
+
+	bucket := int64(step / time.Second)
+	if bucket < 1 {
+		bucket = 1
+	}
+
+	args := append([]any{bucket, bucket, from.Unix(), to.Unix()}, filterArgs...)
+	where := ""
+	if s.where != "" {
+		where = " AND " + s.where
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT (timestamp_unix / ?) * ? AS bucket, %s(%s)
+		FROM %s
+		WHERE timestamp_unix BETWEEN ? AND ?%s
+		GROUP BY bucket
+		ORDER BY bucket
+	`, s.agg, s.col, s.table, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []SeriesPoint{}
+	for rows.Next() {
+		var ts int64
+		var v float64
+		if err := rows.Scan(&ts, &v); err != nil {
+			return nil, err
+		}
+		out = append(out, SeriesPoint{Time: time.Unix(ts, 0), Value: v})
+	}
+	return out, rows.Err()
+}
+
+func queryPeriodSeries(db *sql.DB, query, layout string, from, to time.Time, loc *time.Location) ([]SeriesPoint, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []SeriesPoint{}
+	for rows.Next() {
+		var key string
+		var v float64
+		if err := rows.Scan(&key, &v); err != nil {
+			return nil, err
+		}
+		t, err := time.ParseInLocation(layout, key, loc)
+		if err != nil || t.Before(from) || t.After(to) {
+			continue
+		}
+		out = append(out, SeriesPoint{Time: t, Value: v})
+	}
+	return out, rows.Err()
+}
+
+// QueryTable liefert eine der in TableNames genannten Tabellen.
+// "yearly_energy_cost_current" ist das Jahr von now ([time] timezone).
+func QueryTable(db *sql.DB, name string, now time.Time) (Table, error) {
+	query, ok := tables[name]
+	if !ok {
+		return Table{}, fmt.Errorf("unbekannte Tabelle: %s", name)
+	}
+	var args []any
+	if name == "yearly_energy_cost_current" {
+		args = append(args, now.Year())
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return Table{}, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return Table{}, err
+	}
+	t := Table{Rows: [][]any{}}
+	for _, c := range cols {
+		typ := "number"
+		if textColumns[c] {
+			typ = "string"
+		}
+		t.Columns = append(t.Columns, Column{Text: c, Type: typ})
+	}
+
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return Table{}, err
+		}
+		for i, v := range vals {
+			if b, ok := v.([]byte); ok {
+				vals[i] = string(b)
+			}
+		}
+		t.Rows = append(t.Rows, vals)
+	}
+	return t, rows.Err()
+}
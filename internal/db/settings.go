@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// Tarif kann zur Laufzeit (CLI/API) geändert werden und überschreibt
+// dann [cost] per_kwh aus der Config
+
+const settingTariff = "cost.per_kwh"
+
+// Tariff liefert den aktuellen Preis pro kWh (oder fallback aus der Config)
+func Tariff(db *sql.DB, fallback float64) float64 {
+	var v string
+	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, settingTariff).Scan(&v)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Fehler beim Lesen des Tarifs: %v", err)
+		}
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Ungültiger Tarif in settings: %q", v)
+		return fallback
+	}
+	return f
+}
+
+// SetTariff speichert einen neuen Preis pro kWh
+func SetTariff(db *sql.DB, perKWh float64) error {
+	if perKWh < 0 {
+		return fmt.Errorf("Tarif darf nicht negativ sein: %v", perKWh)
+	}
+	_, err := db.Exec(`
+		INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, settingTariff, strconv.FormatFloat(perKWh, 'f', -1, 64))
+	return err
+}
@@ -0,0 +1,35 @@
+package health
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+)
+
+// Coverage liefert Lücken und Vollständigkeit aller konfigurierten Quellen
+// pro Gerät und Tag (in [time] timezone)
+func Coverage(dbh *sql.DB, cfg config.Config, from, to time.Time) ([]db.DayCoverage, error) {
+	loc := cfg.Time.Location()
+	out := []db.DayCoverage{}
+	for _, source := range Sources(cfg) {
+		table, ok := sourceTables[source]
+		if !ok {
+			continue
+		}
+		c, err := db.Coverage(dbh, source, table, cfg.Health.ExpectedIntervalFor(source), from, to, loc)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c...)
+	}
+	return out, nil
+}
+
+// CoverageDays liefert den Zeitraum der letzten days Kalendertage bis now
+func CoverageDays(cfg config.Config, days int, now time.Time) (from, to time.Time) {
+	loc := cfg.Time.Location()
+	y, m, d := now.In(loc).Date()
+	return time.Date(y, m, d-days+1, 0, 0, 0, 0, loc), now
+}
@@ -0,0 +1,367 @@
+package health
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+// DefaultStaleAfter gilt, wenn in [health] kein stale_after gesetzt ist
+const DefaultStaleAfter = 15 * time.Minute
+
+// Status eines einzelnen Checks bzw. des Gesamtberichts
+type Status string
+
+const (
+	OK       Status = "ok"
+	Warning  Status = "warning"
+	Critical Status = "critical"
+	Unknown  Status = "unknown"
+)
+
+// ExitCode liefert den Nagios-kompatiblen Exit-Code
+func (s Status) ExitCode() int {
+	switch s {
+	case OK:
+		return 0
+	case Warning:
+		return 1
+	case Critical:
+		return 2
+	}
+	return 3
+}
+
+func (s Status) severity() int {
+	switch s {
+	case OK:
+		return 0
+	case Warning:
+		return 1
+	case Unknown:
+		return 2
+	}
+	return 3
+}
+
+// Check ist das Ergebnis einer einzelnen Prüfung
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report fasst mehrere Checks zusammen
+type Report struct {
+	Status Status  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+func newReport(checks []Check) Report {
+	r := Report{Status: OK, Checks: checks}
+	for _, c := range checks {
+		if c.Status.severity() > r.Status.severity() {
+			r.Status = c.Status
+		}
+	}
+	return r
+}
+
+// Summary liefert eine einzeilige Zusammenfassung im Nagios-Stil
+func (r Report) Summary() string {
+	var failed []string
+	for _, c := range r.Checks {
+		if c.Status != OK {
+			failed = append(failed, c.Name+": "+c.Message)
+		}
+	}
+	label := map[Status]string{OK: "OK", Warning: "WARNING", Critical: "CRITICAL", Unknown: "UNKNOWN"}[r.Status]
+	if len(failed) == 0 {
+		return fmt.Sprintf("%s - %d Checks ok", label, len(r.Checks))
+	}
+	msg := label + " - " + failed[0]
+	for _, f := range failed[1:] {
+		msg += "; " + f
+	}
+	return msg
+}
+
+// -------------------------------------------------------------------
+// Tracker – merkt sich Verbindungsstatus und letzten Empfang pro Quelle
+// -------------------------------------------------------------------
+
+// Tracker wird von den MQTT-Handlern gefüttert
+type Tracker struct {
+	mu        sync.Mutex
+	connected map[string]bool
+	lastSeen  map[string]time.Time
+}
+
+// NewTracker erstellt einen leeren Tracker
+func NewTracker() *Tracker {
+	return &Tracker{connected: map[string]bool{}, lastSeen: map[string]time.Time{}}
+}
+
+// Default ist der Tracker des laufenden Prozesses
+var Default = NewTracker()
+
+// SetConnected setzt den Verbindungsstatus eines Brokers
+func (t *Tracker) SetConnected(broker string, c bool) {
+	t.mu.Lock()
+	t.connected[broker] = c
+	t.mu.Unlock()
+}
+
+// Connected liefert den Verbindungsstatus eines Brokers
+func (t *Tracker) Connected(broker string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.connected[broker]
+}
+
+// Seen vermerkt, dass für source gerade Daten gespeichert wurden
+func (t *Tracker) Seen(source string) {
+	t.SeenAt(source, time.Now())
+}
+
+// SeenAt vermerkt einen Empfang zu einem bestimmten Zeitpunkt (nur wenn neuer)
+func (t *Tracker) SeenAt(source string, ts time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ts.After(t.lastSeen[source]) {
+		t.lastSeen[source] = ts
+	}
+}
+
+// LastSeen liefert den Zeitpunkt des letzten Empfangs
+func (t *Tracker) LastSeen(source string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ts, ok := t.lastSeen[source]
+	return ts, ok
+}
+
+// Seed übernimmt die letzten Zeitstempel aus der DB, damit nach einem
+// Neustart nicht sofort alle Quellen als "keine Daten" gelten
+func (t *Tracker) Seed(db *sql.DB, cfg config.Config) {
+	for _, src := range Sources(cfg) {
+		if ts, ok := LastSeenInDB(db, src); ok {
+			t.SeenAt(src, ts)
+		}
+	}
+}
+
+// -------------------------------------------------------------------
+// Quellen
+// -------------------------------------------------------------------
+
+var sourceTables = map[string]string{
+	"wattwaechter": "energy_data",
+	"tasmota":      "tasmota_data",
+	"shelly":       "shelly_data",
+	"zigbee":       "zigbee_data",
+	"sml":          "meter_data",
+	"solar":        "solar_data",
+}
+
+// Sources liefert die konfigurierten Datenquellen über alle Broker
+func Sources(cfg config.Config) []string {
+	var ww, sml, tas, shelly, zigbee, solar bool
+	for _, b := range cfg.BrokerList() {
+		ww = ww || b.Topics.Wattwaechter != ""
+		tas = tas || len(b.Topics.Tasmota) > 0
+		shelly = shelly || len(b.Topics.Shelly) > 0
+		zigbee = zigbee || b.Topics.Zigbee2MQTT != ""
+		sml = sml || len(b.Topics.SML) > 0
+		solar = solar || b.Topics.Solar != ""
+	}
+
+	var out []string
+	if ww {
+		out = append(out, "wattwaechter")
+	}
+	if sml {
+		out = append(out, "sml")
+	}
+	if tas {
+		out = append(out, "tasmota")
+	}
+	if shelly {
+		out = append(out, "shelly")
+	}
+	if zigbee {
+		out = append(out, "zigbee")
+	}
+	if solar {
+		out = append(out, "solar")
+	}
+	return out
+}
+
+// LastSeenInDB liefert den jüngsten Zeitstempel einer Quelle aus der DB
+func LastSeenInDB(db *sql.DB, source string) (time.Time, bool) {
+	table, ok := sourceTables[source]
+	if !ok {
+		return time.Time{}, false
+	}
+	var ts sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(timestamp_unix) FROM ` + table).Scan(&ts); err != nil || !ts.Valid {
+		return time.Time{}, false
+	}
+	return time.Unix(ts.Int64, 0), true
+}
+
+// StaleAfter liefert das Zeitfenster für eine Quelle
+func StaleAfter(cfg config.Config, source string) time.Duration {
+	if d, ok := cfg.Health.StaleAfterSource[source]; ok && d > 0 {
+		return d
+	}
+	if cfg.Health.StaleAfter > 0 {
+		return cfg.Health.StaleAfter
+	}
+	return DefaultStaleAfter
+}
+
+// -------------------------------------------------------------------
+// Checks
+// -------------------------------------------------------------------
+
+// Checker führt die Prüfungen aus
+type Checker struct {
+	Cfg config.Config
+	DB  *sql.DB
+
+	// Connected liefert den Status eines Brokers; nil bedeutet "nicht prüfen"
+	Connected func(b config.BrokerConfig) (bool, error)
+
+	// LastSeen liefert den letzten Empfang einer Quelle
+	LastSeen func(source string) (time.Time, bool)
+
+	Now func() time.Time
+}
+
+// NewChecker erstellt einen Checker, der den Tracker des Prozesses nutzt
+func NewChecker(cfg config.Config, db *sql.DB, t *Tracker) *Checker {
+	return &Checker{
+		Cfg:       cfg,
+		DB:        db,
+		Connected: func(b config.BrokerConfig) (bool, error) { return t.Connected(b.Name), nil },
+		LastSeen:  t.LastSeen,
+		Now:       time.Now,
+	}
+}
+
+// Liveness prüft die Broker-Verbindungen und die DB
+func (c *Checker) Liveness() Report {
+	return newReport(append(c.checkMQTT(), c.checkDB()))
+}
+
+// Readiness prüft zusätzlich die Aktualität der Daten pro Quelle
+func (c *Checker) Readiness() Report {
+	checks := append(c.checkMQTT(), c.checkDB())
+	for _, src := range Sources(c.Cfg) {
+		checks = append(checks, c.checkFreshness(src))
+	}
+	return newReport(checks)
+}
+
+// checkMQTT prüft jeden Broker; bei mehreren heißen die Checks "mqtt:<name>"
+func (c *Checker) checkMQTT() []Check {
+	brokers := c.Cfg.BrokerList()
+	checks := make([]Check, 0, len(brokers))
+	for _, b := range brokers {
+		name := "mqtt"
+		if len(brokers) > 1 {
+			name += ":" + b.Name
+		}
+		checks = append(checks, c.checkBroker(name, b))
+	}
+	return checks
+}
+
+func (c *Checker) checkBroker(name string, b config.BrokerConfig) Check {
+	if c.Connected == nil {
+		return Check{Name: name, Status: Unknown, Message: "nicht geprüft"}
+	}
+	ok, err := c.Connected(b)
+	switch {
+	case err != nil:
+		return Check{Name: name, Status: Critical, Message: err.Error()}
+	case !ok:
+		return Check{Name: name, Status: Critical, Message: "nicht verbunden"}
+	}
+	return Check{Name: name, Status: OK, Message: "verbunden"}
+}
+
+func (c *Checker) checkDB() Check {
+	if err := ProbeWritable(c.DB); err != nil {
+		return Check{Name: "db", Status: Critical, Message: "nicht schreibbar: " + err.Error()}
+	}
+	return Check{Name: "db", Status: OK, Message: "schreibbar"}
+}
+
+func (c *Checker) checkFreshness(source string) Check {
+	name := "data:" + source
+	ts, ok := c.LastSeen(source)
+	if !ok {
+		return Check{Name: name, Status: Warning, Message: "noch keine Daten empfangen"}
+	}
+	age := c.Now().Sub(ts).Truncate(time.Second)
+	limit := StaleAfter(c.Cfg, source)
+	if age > limit {
+		return Check{Name: name, Status: Critical, Message: fmt.Sprintf("keine Daten seit %s (Limit %s)", age, limit)}
+	}
+	return Check{Name: name, Status: OK, Message: fmt.Sprintf("letzte Daten vor %s", age)}
+}
+
+// ProbeWritable prüft, ob die DB eine Schreibtransaktion bekommt,
+// ohne dabei Daten zu verändern
+func ProbeWritable(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Ein DELETE ohne Treffer holt sich trotzdem die Schreibsperre
+	_, err = tx.Exec(`DELETE FROM energy_data WHERE id < 0`)
+	return err
+}
+
+// -------------------------------------------------------------------
+// Abfrage eines laufenden Dienstes
+// -------------------------------------------------------------------
+
+// LocalURL baut aus einer Listen-Adresse eine lokal erreichbare URL
+func LocalURL(listen, path string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "http://" + listen + path
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port) + path
+}
+
+// Fetch holt einen Report vom laufenden Dienst
+func Fetch(url string, timeout time.Duration) (Report, error) {
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return Report{}, err
+	}
+	defer resp.Body.Close()
+
+	var r Report
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return Report{}, fmt.Errorf("ungültige Antwort (%s): %w", resp.Status, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,131 @@
+package health
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newHealthTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE energy_data (id INTEGER PRIMARY KEY, timestamp_unix INTEGER)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	return db
+}
+
+func TestReadinessFlagsStaleSources(t *testing.T) {
+	db := newHealthTestDB(t)
+	defer db.Close()
+
+	now := time.Date(2025, 11, 24, 12, 0, 0, 0, time.UTC)
+	cfg := config.Config{
+		Topics: config.TopicsConfig{Wattwaechter: "tele/ww/SENSOR", Tasmota: config.TopicList{"tele/plug/SENSOR"}},
+		Health: config.HealthConfig{
+			StaleAfter:       10 * time.Minute,
+			StaleAfterSource: map[string]time.Duration{"tasmota": time.Hour},
+		},
+	}
+
+	tr := NewTracker()
+	tr.SetConnected("default", true)
+	tr.SeenAt("wattwaechter", now.Add(-20*time.Minute)) // stale: limit 10m
+	tr.SeenAt("tasmota", now.Add(-20*time.Minute))      // fresh: limit 1h
+
+	c := NewChecker(cfg, db, tr)
+	c.Now = func() time.Time { return now }
+
+	if r := c.Liveness(); r.Status != OK {
+		t.Fatalf("liveness: expected ok, got %s (%s)", r.Status, r.Summary())
+	}
+
+	r := c.Readiness()
+	if r.Status != Critical || r.Status.ExitCode() != 2 {
+		t.Fatalf("readiness: expected critical, got %s", r.Status)
+	}
+	got := map[string]Status{}
+	for _, ch := range r.Checks {
+		got[ch.Name] = ch.Status
+	}
+	if got["data:wattwaechter"] != Critical || got["data:tasmota"] != OK {
+		t.Fatalf("unexpected freshness results: %v", got)
+	}
+}
+
+func TestReadinessWarnsWithoutData(t *testing.T) {
+	db := newHealthTestDB(t)
+	defer db.Close()
+
+	cfg := config.Config{Topics: config.TopicsConfig{Wattwaechter: "tele/ww/SENSOR"}}
+	tr := NewTracker()
+	tr.SetConnected("default", true)
+	tr.Seed(db, cfg) // empty table -> nothing seeded
+
+	r := NewChecker(cfg, db, tr).Readiness()
+	if r.Status != Warning || r.Status.ExitCode() != 1 {
+		t.Fatalf("expected warning, got %s (%s)", r.Status, r.Summary())
+	}
+}
+
+func TestChecksEveryBroker(t *testing.T) {
+	db := newHealthTestDB(t)
+	defer db.Close()
+
+	cfg := config.Config{Brokers: []config.BrokerConfig{
+		{Name: "keller", Topics: config.TopicsConfig{Wattwaechter: "tele/ww/SENSOR"}},
+		{Topics: config.TopicsConfig{Tasmota: config.TopicList{"tele/+/SENSOR"}, Solar: "solar/#"}},
+	}}
+	if got := Sources(cfg); len(got) != 3 {
+		t.Fatalf("expected sources of all brokers, got %v", got)
+	}
+
+	tr := NewTracker()
+	tr.SetConnected("keller", true)
+
+	r := NewChecker(cfg, db, tr).Liveness()
+	got := map[string]Status{}
+	for _, ch := range r.Checks {
+		got[ch.Name] = ch.Status
+	}
+	if got["mqtt:keller"] != OK || got["mqtt:broker2"] != Critical || r.Status != Critical {
+		t.Fatalf("unexpected broker checks: %v", got)
+	}
+}
+
+func TestSeedUsesLatestTimestamp(t *testing.T) {
+	db := newHealthTestDB(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO energy_data (timestamp_unix) VALUES (100), (300), (200)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	tr := NewTracker()
+	tr.Seed(db, config.Config{Topics: config.TopicsConfig{Wattwaechter: "x"}})
+
+	ts, ok := tr.LastSeen("wattwaechter")
+	if !ok || ts.Unix() != 300 {
+		t.Fatalf("expected 300, got %v (ok=%v)", ts.Unix(), ok)
+	}
+}
+
+func TestLocalURL(t *testing.T) {
+	cases := map[string]string{
+		":9100":          "http://127.0.0.1:9100/readyz",
+		"0.0.0.0:9100":   "http://127.0.0.1:9100/readyz",
+		"10.0.0.5:8080":  "http://10.0.0.5:8080/readyz",
+		"localhost:9100": "http://localhost:9100/readyz",
+	}
+	for in, want := range cases {
+		if got := LocalURL(in, "/readyz"); got != want {
+			t.Fatalf("LocalURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
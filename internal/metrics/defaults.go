@@ -0,0 +1,128 @@
+package metrics
+
+// Default ist die Registry, die unter /metrics ausgeliefert wird
+var Default = NewRegistry()
+
+// -------------------------------------------------------------------
+// Betriebsmetriken
+// -------------------------------------------------------------------
+
+var (
+	MessagesReceived = Default.NewCounter(
+		"mqttlogger_messages_received_total",
+		"Empfangene MQTT-Nachrichten pro Handler.",
+		"handler",
+	)
+
+	MessagesStored = Default.NewCounter(
+		"mqttlogger_messages_stored_total",
+		"Erfolgreich gespeicherte Messwerte pro Handler.",
+		"handler",
+	)
+
+	// Errors zählt die Fehlerpfade der Handler, kind ist z.B. json, time oder db
+	Errors = Default.NewCounter(
+		"mqttlogger_errors_total",
+		"Fehler pro Handler und Art (json, time, db, ...).",
+		"handler", "kind",
+	)
+
+	WriteLatency = Default.NewHistogram(
+		"mqttlogger_db_write_duration_seconds",
+		"Dauer der DB-Inserts in Sekunden.",
+		[]float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	)
+
+	MQTTConnected = Default.NewGauge(
+		"mqttlogger_mqtt_connected",
+		"1 wenn die Verbindung zum Broker steht, sonst 0.",
+		"broker",
+	)
+
+	Duplicates = Default.NewCounter(
+		"mqttlogger_duplicates_total",
+		"Verworfene doppelte Messwerte (gleiche Quelle, Gerät, Zeitstempel).",
+		"source",
+	)
+
+	RetainedSkipped = Default.NewCounter(
+		"mqttlogger_retained_skipped_total",
+		"Verworfene retained Nachrichten pro Broker ([dedup] skip_retained).",
+		"broker",
+	)
+
+	Quarantined = Default.NewCounter(
+		"mqttlogger_quarantined_total",
+		"Abgelehnte Messwerte pro Quelle und Regel (range, rate, monotonic).",
+		"source", "rule",
+	)
+
+	// ClockSkew zählt Messwerte, deren Geräte-Uhr über warn_skew abweicht
+	ClockSkew = Default.NewCounter(
+		"mqttlogger_clock_skew_warnings_total",
+		"Messwerte mit abweichender Geräte-Uhr pro Quelle.",
+		"source",
+	)
+
+	ClockSkewSeconds = Default.NewGauge(
+		"mqttlogger_clock_skew_seconds",
+		"Letzte Abweichung der Geräte-Uhr (Gerät minus Empfang) in Sekunden.",
+		"source", "device",
+	)
+
+	AggregationDuration = Default.NewGauge(
+		"mqttlogger_aggregation_duration_seconds",
+		"Dauer des letzten Aggregationslaufs in Sekunden.",
+	)
+)
+
+// -------------------------------------------------------------------
+// Fachliche Metriken
+// -------------------------------------------------------------------
+
+var (
+	Power = Default.NewGauge(
+		"mqttlogger_power_watts",
+		"Aktuelle Leistung laut Wattwaechter.",
+	)
+
+	EnergyIn = Default.NewGauge(
+		"mqttlogger_energy_in_kwh",
+		"Zählerstand Bezug (E_in).",
+	)
+
+	EnergyOut = Default.NewGauge(
+		"mqttlogger_energy_out_kwh",
+		"Zählerstand Einspeisung (E_out).",
+	)
+
+	PhasePower = Default.NewGauge(
+		"mqttlogger_phase_power_watts",
+		"Leistung pro Phase (Wattwaechter, falls geliefert).",
+		"phase",
+	)
+
+	TasmotaPower = Default.NewGauge(
+		"mqttlogger_tasmota_power_watts",
+		"Aktuelle Leistung pro Tasmota-Gerät.",
+		"device",
+	)
+
+	ShellyPower = Default.NewGauge(
+		"mqttlogger_shelly_power_watts",
+		"Aktuelle Leistung pro Shelly-Gerät und Kanal bzw. Phase.",
+		"device", "channel",
+	)
+
+	ZigbeePower = Default.NewGauge(
+		"mqttlogger_zigbee_power_watts",
+		"Aktuelle Leistung pro Zigbee2MQTT-Gerät.",
+		"device",
+	)
+
+	MeterPower = Default.NewGauge(
+		"mqttlogger_meter_power_watts",
+		"Aktuelle Leistung pro SML/D0-Zähler (negativ = Einspeisung).",
+		"meter",
+	)
+)
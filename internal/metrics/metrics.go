@@ -0,0 +1,276 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Minimaler Prometheus-Exporter im Text-Format (Version 0.0.4).
+// Bewusst ohne client_golang, damit das Binary klein bleibt.
+
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry hält alle registrierten Metriken
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry erstellt eine leere Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteText schreibt alle Metriken im Prometheus-Textformat
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	cs := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	for _, c := range cs {
+		c.write(w)
+	}
+}
+
+// Handler liefert einen HTTP-Handler für /metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// -------------------------------------------------------------------
+// Vektoren (Counter & Gauge mit Labels)
+// -------------------------------------------------------------------
+
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newVec(r *Registry, name, help, kind string, labels []string) *vec {
+	v := &vec{name: name, help: help, kind: kind, labels: labels, values: map[string]float64{}}
+	r.register(v)
+	return v
+}
+
+func (v *vec) key(lv []string) string {
+	if len(lv) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s erwartet %d Labels, bekam %d", v.name, len(v.labels), len(lv)))
+	}
+	return strings.Join(lv, "\xff")
+}
+
+func (v *vec) add(delta float64, lv []string) {
+	k := v.key(lv)
+	v.mu.Lock()
+	v.values[k] += delta
+	v.mu.Unlock()
+}
+
+func (v *vec) set(val float64, lv []string) {
+	k := v.key(lv)
+	v.mu.Lock()
+	v.values[k] = val
+	v.mu.Unlock()
+}
+
+func (v *vec) get(lv []string) float64 {
+	k := v.key(lv)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.values[k]
+}
+
+func (v *vec) write(w io.Writer) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	vals := make([]float64, len(keys))
+	for i, k := range keys {
+		vals[i] = v.values[k]
+	}
+	v.mu.Unlock()
+
+	writeHeader(w, v.name, v.help, v.kind)
+	if len(v.labels) == 0 && len(keys) == 0 {
+		fmt.Fprintf(w, "%s 0\n", v.name)
+		return
+	}
+	for i, k := range keys {
+		var lv []string
+		if len(v.labels) > 0 {
+			lv = strings.Split(k, "\xff")
+		}
+		fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, lv), formatFloat(vals[i]))
+	}
+}
+
+// Counter ist ein monoton steigender Zähler mit optionalen Labels
+type Counter struct{ v *vec }
+
+// NewCounter registriert einen Counter
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{v: newVec(r, name, help, "counter", labels)}
+}
+
+// Inc erhöht den Counter um 1
+func (c *Counter) Inc(labelValues ...string) { c.v.add(1, labelValues) }
+
+// Add erhöht den Counter um delta (negative Werte werden ignoriert)
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.v.add(delta, labelValues)
+}
+
+// Value liefert den aktuellen Stand
+func (c *Counter) Value(labelValues ...string) float64 { return c.v.get(labelValues) }
+
+// Gauge ist ein beliebig setzbarer Messwert mit optionalen Labels
+type Gauge struct{ v *vec }
+
+// NewGauge registriert eine Gauge
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{v: newVec(r, name, help, "gauge", labels)}
+}
+
+// Set setzt den Wert
+func (g *Gauge) Set(val float64, labelValues ...string) { g.v.set(val, labelValues) }
+
+// Value liefert den aktuellen Wert
+func (g *Gauge) Value(labelValues ...string) float64 { return g.v.get(labelValues) }
+
+// -------------------------------------------------------------------
+// GaugeFunc – Wert wird beim Scrape berechnet (z.B. DB-Größe)
+// -------------------------------------------------------------------
+
+type gaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+// NewGaugeFunc registriert eine Gauge, deren Wert beim Scrape ermittelt wird
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&gaugeFunc{name: name, help: help, fn: fn})
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// -------------------------------------------------------------------
+// Histogram
+// -------------------------------------------------------------------
+
+// Histogram zählt Beobachtungen in festen Buckets
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registriert ein Histogram mit aufsteigenden Bucket-Grenzen
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe trägt einen Messwert ein
+func (h *Histogram) Observe(val float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, b := range h.buckets {
+		if val <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += val
+	h.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	for i, b := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(b), counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, count)
+}
+
+// -------------------------------------------------------------------
+// Formatierung
+// -------------------------------------------------------------------
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = fmt.Sprintf(`%s="%s"`, n, labelEscaper.Replace(values[i]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTextFormatsAllKinds(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.NewCounter("test_messages_total", "Nachrichten.", "handler")
+	c.Inc("tasmota")
+	c.Inc("tasmota")
+	c.Add(3, "solar")
+
+	g := r.NewGauge("test_power_watts", "Leistung.", "device")
+	g.Set(42.5, `plug"1`)
+
+	r.NewGaugeFunc("test_size_bytes", "Größe.", func() float64 { return 1024 })
+
+	h := r.NewHistogram("test_latency_seconds", "Latenz.", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+
+	var sb strings.Builder
+	r.WriteText(&sb)
+	out := sb.String()
+
+	want := []string{
+		"# TYPE test_messages_total counter",
+		`test_messages_total{handler="solar"} 3`,
+		`test_messages_total{handler="tasmota"} 2`,
+		"# TYPE test_power_watts gauge",
+		`test_power_watts{device="plug\"1"} 42.5`,
+		"test_size_bytes 1024",
+		`test_latency_seconds_bucket{le="0.1"} 1`,
+		`test_latency_seconds_bucket{le="1"} 2`,
+		`test_latency_seconds_bucket{le="+Inf"} 2`,
+		"test_latency_seconds_sum 0.55",
+		"test_latency_seconds_count 2",
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Fatalf("output missing %q:\n%s", w, out)
+		}
+	}
+}
+
+func TestUnlabelledGaugeStartsAtZero(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("test_connected", "Verbunden.")
+
+	var sb strings.Builder
+	r.WriteText(&sb)
+	if !strings.Contains(sb.String(), "test_connected 0\n") {
+		t.Fatalf("expected zero sample, got:\n%s", sb.String())
+	}
+}
@@ -0,0 +1,183 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+// -------------------------------------------------------------------
+// Client-Abstraktion über MQTT 3.1.1 (paho.mqtt.golang) und
+// MQTT 5 (paho.golang/autopaho)
+// -------------------------------------------------------------------
+
+const (
+	defaultConnectTimeout = 10 * time.Second
+	publishTimeout        = 5 * time.Second
+)
+
+// retryInterval ist der Abstand zwischen Verbindungsversuchen bei AutoReconnect
+var retryInterval = 10 * time.Second
+
+// Message ist eine empfangene Nachricht, unabhängig von der Protokollversion
+type Message struct {
+	Topic   string
+	Payload []byte
+	// Retained ist gesetzt, wenn der Broker eine gespeicherte Nachricht
+	// ausliefert (z.B. direkt nach dem Subscribe)
+	Retained bool
+}
+
+// Handler verarbeitet eine empfangene Nachricht
+type Handler func(Message)
+
+// Subscription ist ein Topic-Filter mit zugehörigem Handler
+type Subscription struct {
+	Topic   string
+	Qos     byte
+	Handler Handler
+}
+
+// publisher ist der Teil des Clients, den die Veröffentlichung braucht
+type publisher interface {
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+}
+
+// Client ist die gemeinsame Sicht auf die Broker-Verbindung
+type Client interface {
+	publisher
+	Subscribe(s Subscription) error
+	Disconnect()
+}
+
+// will ist die Last-Will-Nachricht
+type will struct {
+	Topic   string
+	Payload string
+	Qos     byte
+}
+
+// clientOptions sind die protokollunabhängigen Verbindungsparameter.
+// Subscriptions werden bei jedem (Wieder-)Verbindungsaufbau erneuert.
+type clientOptions struct {
+	Broker         config.BrokerConfig
+	ClientID       string
+	Will           *will
+	Subscriptions  []Subscription
+	ConnectTimeout time.Duration
+	AutoReconnect  bool
+	OnConnect      func(Client)
+	OnLost         func(error)
+
+	tls   *tls.Config
+	proxy func(*http.Request) (*url.URL, error)
+}
+
+// protocolV5 meldet, ob für den Broker MQTT 5 konfiguriert ist
+func protocolV5(b config.BrokerConfig) (bool, error) {
+	switch strings.TrimPrefix(strings.ToLower(b.Protocol), "v") {
+	case "", "3", "3.1.1", "311":
+		return false, nil
+	case "5", "5.0":
+		return true, nil
+	}
+	return false, fmt.Errorf("unbekanntes MQTT-Protokoll %q (erlaubt: 3.1.1, 5)", b.Protocol)
+}
+
+// connect baut die Verbindung mit der konfigurierten Protokollversion auf
+// und wartet höchstens ConnectTimeout auf den ersten Verbindungsaufbau.
+// Mit AutoReconnect liefert ein Timeout den Client zusammen mit dem Fehler;
+// er versucht es im Hintergrund weiter.
+func connect(o clientOptions) (Client, error) {
+	if o.ConnectTimeout <= 0 {
+		o.ConnectTimeout = defaultConnectTimeout
+	}
+	tlsCfg, err := tlsConfig(o.Broker.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("TLS: %w", err)
+	}
+	o.tls = tlsCfg
+	if o.proxy, err = wsProxy(o.Broker.Proxy); err != nil {
+		return nil, err
+	}
+	v5, err := protocolV5(o.Broker)
+	if err != nil {
+		return nil, err
+	}
+	if v5 {
+		return connectV5(o)
+	}
+	return connectV3(o)
+}
+
+// tlsConfig baut die TLS-Konfiguration; nil, wenn nichts angegeben ist
+func tlsConfig(t config.TLSConfig) (*tls.Config, error) {
+	if t == (config.TLSConfig{}) {
+		return nil, nil
+	}
+	c := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("keine Zertifikate in %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+// wsHeaders liefert die Header für den WebSocket-Handshake
+func wsHeaders(h map[string]string) http.Header {
+	out := http.Header{}
+	for k, v := range h {
+		out.Set(k, v)
+	}
+	return out
+}
+
+// wsProxy liefert die Proxy-Auswahl für WebSocket-Verbindungen
+func wsProxy(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	switch strings.ToLower(proxy) {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case "none", "direct":
+		// nicht nil: paho.mqtt.golang würde sonst die Umgebung auswerten
+		return func(*http.Request) (*url.URL, error) { return nil, nil }, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("ungültige Proxy-URL %q", proxy)
+	}
+	return http.ProxyURL(u), nil
+}
+
+func logSubscribeError(topic string, err error) {
+	log.Printf("MQTT Subscribe %s fehlgeschlagen: %v", topic, err)
+}
+
+// sharedTopic stellt einem Topic-Filter "$share/<group>/" voran, damit sich
+// mehrere Instanzen die Nachrichten teilen
+func sharedTopic(group, topic string) string {
+	if group == "" || strings.HasPrefix(topic, "$share/") {
+		return topic
+	}
+	return "$share/" + group + "/" + topic
+}
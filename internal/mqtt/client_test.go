@@ -0,0 +1,170 @@
+package mqtt
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+func TestProtocolSelection(t *testing.T) {
+	for proto, want := range map[string]bool{"": false, "3.1.1": false, "v3": false, "5": true, "v5": true, "5.0": true} {
+		got, err := protocolV5(config.BrokerConfig{Protocol: proto})
+		if err != nil || got != want {
+			t.Errorf("protocol %q: got %v, %v", proto, got, err)
+		}
+	}
+	if _, err := protocolV5(config.BrokerConfig{Protocol: "4"}); err == nil {
+		t.Error("expected error for unknown protocol")
+	}
+}
+
+func TestSharedTopic(t *testing.T) {
+	if got := sharedTopic("", "tele/+/SENSOR"); got != "tele/+/SENSOR" {
+		t.Errorf("without group: %s", got)
+	}
+	if got := sharedTopic("logger", "tele/+/SENSOR"); got != "$share/logger/tele/+/SENSOR" {
+		t.Errorf("with group: %s", got)
+	}
+	if got := sharedTopic("logger", "$share/other/solar/#"); got != "$share/other/solar/#" {
+		t.Errorf("already shared: %s", got)
+	}
+}
+
+func TestBrokerURLSchemes(t *testing.T) {
+	for in, want := range map[string]string{
+		"tcp://broker:1883":  "mqtt://broker:1883",
+		"ssl://broker:8883":  "tls://broker:8883",
+		"broker:1883":        "mqtt://broker:1883",
+		"mqtt://broker:1883": "mqtt://broker:1883",
+	} {
+		u, err := brokerURL(in)
+		if err != nil || u.String() != want {
+			t.Errorf("%s: got %v, %v", in, u, err)
+		}
+	}
+}
+
+func TestProbeFailsWithoutBroker(t *testing.T) {
+	for _, proto := range []string{"3.1.1", "5"} {
+		b := config.BrokerConfig{Host: "tcp://127.0.0.1:1", ClientID: "probe-test", Protocol: proto}
+		if err := Probe(b, 500*time.Millisecond); err == nil {
+			t.Errorf("protocol %s: expected error without broker", proto)
+		}
+	}
+}
+
+func TestV5ReconnectRoutesOnce(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// jeder Verbindungsaufbau bekommt nach dem SUBSCRIBE eine Nachricht
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go serveV5(conn, func(write func(io.Writer) error) bool { return write(conn) == nil }, []byte("42"))
+		}
+	}()
+
+	got := make(chan Message, 8)
+	c, err := connect(clientOptions{
+		Broker:         config.BrokerConfig{Host: "tcp://" + ln.Addr().String(), Protocol: "5"},
+		ClientID:       "reconnect-test",
+		ConnectTimeout: 2 * time.Second,
+		AutoReconnect:  true,
+		Subscriptions: []Subscription{{Topic: "tele/plug/SENSOR", Handler: func(m Message) {
+			got <- m
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer c.Disconnect()
+
+	wait := func(what string) {
+		t.Helper()
+		select {
+		case <-got:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no message %s", what)
+		}
+	}
+	wait("before reconnect")
+	(<-conns).Close()
+	wait("after reconnect")
+
+	select {
+	case <-got:
+		t.Fatal("message handled twice after reconnect")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestAutoReconnectRetriesInitialConnect(t *testing.T) {
+	defer func(d time.Duration) { retryInterval = d }(retryInterval)
+	retryInterval = 100 * time.Millisecond
+
+	for _, tc := range []struct {
+		protocol string
+		serve    func(io.Reader, func(func(io.Writer) error) bool, []byte)
+	}{
+		{"3.1.1", serveV311},
+		{"5", serveV5},
+	} {
+		t.Run(tc.protocol, func(t *testing.T) {
+			// freien Port belegen und wieder freigeben: erster Versuch scheitert
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+			addr := ln.Addr().String()
+			ln.Close()
+
+			got := make(chan Message, 8)
+			c, err := connect(clientOptions{
+				Broker:         config.BrokerConfig{Host: "tcp://" + addr, Protocol: tc.protocol},
+				ClientID:       "retry-test",
+				ConnectTimeout: 300 * time.Millisecond,
+				AutoReconnect:  true,
+				Subscriptions: []Subscription{{Topic: "tele/plug/SENSOR", Handler: func(m Message) {
+					got <- m
+				}}},
+			})
+			if err == nil || c == nil {
+				t.Fatalf("connect = %v, %v; want client and error", c, err)
+			}
+			defer c.Disconnect()
+
+			ln, err = net.Listen("tcp", addr)
+			if err != nil {
+				t.Fatalf("listen again: %v", err)
+			}
+			defer ln.Close()
+			go func() {
+				for {
+					conn, err := ln.Accept()
+					if err != nil {
+						return
+					}
+					go tc.serve(conn, func(write func(io.Writer) error) bool { return write(conn) == nil }, []byte("42"))
+				}
+			}()
+
+			select {
+			case <-got:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no message after broker came up")
+			}
+		})
+	}
+}
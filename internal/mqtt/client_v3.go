@@ -0,0 +1,87 @@
+package mqtt
+
+import (
+	"fmt"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// v3Client ist der Adapter für MQTT 3.1.1 (paho.mqtt.golang)
+type v3Client struct {
+	c mqtt.Client
+}
+
+func connectV3(o clientOptions) (Client, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(o.Broker.Host).
+		SetClientID(o.ClientID).
+		SetUsername(o.Broker.Username).
+		SetPassword(o.Broker.Password).
+		SetConnectTimeout(o.ConnectTimeout).
+		SetAutoReconnect(o.AutoReconnect).
+		SetConnectRetry(o.AutoReconnect).
+		SetConnectRetryInterval(retryInterval)
+
+	// WebSocket-Transport (ws://, wss://)
+	opts.SetHTTPHeaders(wsHeaders(o.Broker.Headers))
+	opts.SetWebsocketOptions(&mqtt.WebsocketOptions{Proxy: o.proxy})
+
+	if o.tls != nil {
+		opts.SetTLSConfig(o.tls)
+	}
+	if o.Will != nil {
+		opts.SetWill(o.Will.Topic, o.Will.Payload, o.Will.Qos, true)
+	}
+
+	cl := &v3Client{}
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		for _, s := range o.Subscriptions {
+			if err := cl.Subscribe(s); err != nil {
+				logSubscribeError(s.Topic, err)
+			}
+		}
+		if o.OnConnect != nil {
+			o.OnConnect(cl)
+		}
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		if o.OnLost != nil {
+			o.OnLost(err)
+		}
+	})
+
+	cl.c = mqtt.NewClient(opts)
+	token := cl.c.Connect()
+	if !token.WaitTimeout(o.ConnectTimeout) {
+		if o.AutoReconnect {
+			return cl, fmt.Errorf("Timeout nach %s", o.ConnectTimeout)
+		}
+		cl.c.Disconnect(0)
+		return nil, fmt.Errorf("Timeout nach %s", o.ConnectTimeout)
+	}
+	if err := token.Error(); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+func (c *v3Client) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	return wait(c.c.Publish(topic, qos, retained, payload))
+}
+
+func (c *v3Client) Subscribe(s Subscription) error {
+	return wait(c.c.Subscribe(s.Topic, s.Qos, func(_ mqtt.Client, m mqtt.Message) {
+		s.Handler(Message{Topic: m.Topic(), Payload: m.Payload(), Retained: m.Retained()})
+	}))
+}
+
+func (c *v3Client) Disconnect() {
+	c.c.Disconnect(250)
+}
+
+func wait(token mqtt.Token) error {
+	if !token.WaitTimeout(publishTimeout) {
+		return fmt.Errorf("Timeout nach %s", publishTimeout)
+	}
+	return token.Error()
+}
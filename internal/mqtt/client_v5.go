@@ -0,0 +1,228 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/gorilla/websocket"
+)
+
+// v5Client ist der Adapter für MQTT 5 (paho.golang/autopaho)
+type v5Client struct {
+	cm     *autopaho.ConnectionManager
+	router *paho.StandardRouter
+	cancel context.CancelFunc
+	debug  bool
+}
+
+// brokerURL akzeptiert die von paho.mqtt.golang gewohnten Schemata
+// (tcp://, ssl://) und setzt sie auf die von autopaho um
+func brokerURL(host string) (*url.URL, error) {
+	if !strings.Contains(host, "://") {
+		host = "mqtt://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "tcp":
+		u.Scheme = "mqtt"
+	case "ssl", "tls", "mqtts":
+		u.Scheme = "tls"
+	}
+	return u, nil
+}
+
+func connectV5(o clientOptions) (Client, error) {
+	u, err := brokerURL(o.Broker.Host)
+	if err != nil {
+		return nil, fmt.Errorf("ungültige Broker-URL: %w", err)
+	}
+
+	cl := &v5Client{router: paho.NewStandardRouter(), debug: o.Broker.SetDebug}
+	// Handler einmalig registrieren; RegisterHandler hängt an, bei jedem
+	// Reconnect erneut registriert liefe jede Nachricht mehrfach durch
+	for _, s := range o.Subscriptions {
+		cl.route(s)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cl.cancel = cancel
+
+	cfg := autopaho.ClientConfig{
+		ServerUrls: []*url.URL{u},
+		TlsCfg:     o.tls,
+		WebSocketCfg: &autopaho.WebSocketConfig{
+			Dialer: func(_ *url.URL, tlsCfg *tls.Config) *websocket.Dialer {
+				d := *websocket.DefaultDialer
+				d.TLSClientConfig = tlsCfg
+				d.Subprotocols = []string{"mqtt"}
+				d.Proxy = o.proxy
+				return &d
+			},
+			Header: func(*url.URL, *tls.Config) http.Header {
+				return wsHeaders(o.Broker.Headers)
+			},
+		},
+		KeepAlive:                     30,
+		CleanStartOnInitialConnection: true,
+		ConnectTimeout:                o.ConnectTimeout,
+		ReconnectBackoff:              autopaho.NewConstantBackoff(retryInterval),
+		ConnectUsername:               o.Broker.Username,
+		ConnectPassword:               []byte(o.Broker.Password),
+		OnConnectionUp: func(cm *autopaho.ConnectionManager, ca *paho.Connack) {
+			// OnConnectionUp darf nicht blockieren
+			go func() {
+				for _, s := range o.Subscriptions {
+					if err := cl.subscribe(s); err != nil {
+						logSubscribeError(s.Topic, err)
+					}
+				}
+				if o.OnConnect != nil {
+					o.OnConnect(cl)
+				}
+			}()
+		},
+		OnConnectionDown: func() bool {
+			if o.OnLost != nil {
+				o.OnLost(fmt.Errorf("Verbindung getrennt"))
+			}
+			return o.AutoReconnect
+		},
+		OnConnectError: func(err error) {
+			log.Printf("[MQTT5] Verbindungsfehler: %v", err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: o.ClientID,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(pr paho.PublishReceived) (bool, error) {
+					cl.logProperties(pr.Packet)
+					cl.router.Route(pr.Packet.Packet())
+					return true, nil
+				},
+			},
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				reason := ""
+				if d.Properties != nil {
+					reason = d.Properties.ReasonString
+				}
+				log.Printf("[MQTT5] Server trennt Verbindung: Reason-Code 0x%02x %s", d.ReasonCode, reason)
+			},
+		},
+	}
+	if o.Will != nil {
+		cfg.WillMessage = &paho.WillMessage{
+			Topic:   o.Will.Topic,
+			Payload: []byte(o.Will.Payload),
+			QoS:     o.Will.Qos,
+			Retain:  true,
+		}
+	}
+
+	cm, err := autopaho.NewConnection(ctx, cfg)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	cl.cm = cm
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, o.ConnectTimeout)
+	defer waitCancel()
+	if err := cm.AwaitConnection(waitCtx); err != nil {
+		if o.AutoReconnect {
+			return cl, fmt.Errorf("Timeout nach %s", o.ConnectTimeout)
+		}
+		cancel()
+		return nil, fmt.Errorf("Timeout nach %s", o.ConnectTimeout)
+	}
+	return cl, nil
+}
+
+// logProperties gibt MQTT-5-Eigenschaften eingehender Nachrichten aus
+func (c *v5Client) logProperties(p *paho.Publish) {
+	if !c.debug || p.Properties == nil {
+		return
+	}
+	var parts []string
+	if p.Properties.MessageExpiry != nil {
+		parts = append(parts, fmt.Sprintf("expiry=%ds", *p.Properties.MessageExpiry))
+	}
+	for _, up := range p.Properties.User {
+		parts = append(parts, up.Key+"="+up.Value)
+	}
+	if len(parts) > 0 {
+		log.Printf("[MQTT5] %s Properties: %s", p.Topic, strings.Join(parts, " "))
+	}
+}
+
+func (c *v5Client) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	resp, err := c.cm.Publish(ctx, &paho.Publish{Topic: topic, QoS: qos, Retain: retained, Payload: payload})
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.ReasonCode >= 0x80 {
+		return reasonError("PUBACK", resp.ReasonCode, resp.Properties)
+	}
+	return nil
+}
+
+// Subscribe ersetzt den Handler des Topics und abonniert es
+func (c *v5Client) Subscribe(s Subscription) error {
+	c.route(s)
+	return c.subscribe(s)
+}
+
+// route setzt den Handler für s.Topic (ein vorhandener wird ersetzt)
+func (c *v5Client) route(s Subscription) {
+	c.router.UnregisterHandler(s.Topic)
+	c.router.RegisterHandler(s.Topic, func(p *paho.Publish) {
+		s.Handler(Message{Topic: p.Topic, Payload: p.Payload, Retained: p.Retain})
+	})
+}
+
+// subscribe sendet nur das SUBSCRIBE-Paket
+func (c *v5Client) subscribe(s Subscription) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	ack, err := c.cm.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: s.Topic, QoS: s.Qos}},
+	})
+	if err != nil {
+		return err
+	}
+	for _, rc := range ack.Reasons {
+		if rc >= 0x80 {
+			var props *paho.PublishResponseProperties
+			if ack.Properties != nil {
+				props = &paho.PublishResponseProperties{ReasonString: ack.Properties.ReasonString}
+			}
+			return reasonError("SUBACK", rc, props)
+		}
+	}
+	return nil
+}
+
+func (c *v5Client) Disconnect() {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	_ = c.cm.Disconnect(ctx)
+	c.cancel()
+}
+
+func reasonError(packet string, code byte, props *paho.PublishResponseProperties) error {
+	if props != nil && props.ReasonString != "" {
+		return fmt.Errorf("%s Reason-Code 0x%02x: %s", packet, code, props.ReasonString)
+	}
+	return fmt.Errorf("%s Reason-Code 0x%02x", packet, code)
+}
@@ -0,0 +1,82 @@
+package mqtt
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+)
+
+// -------------------------------------------------------------------
+// Duplikate und retained Nachrichten
+// -------------------------------------------------------------------
+
+// storeReading prüft einen Messwert gegen [validation] und schreibt ihn
+// mit der Konfliktbehandlung aus [dedup]. Abgelehnte Werte landen in der
+// Quarantäne, Duplikate werden gezählt; beides ergibt stored = false.
+func storeReading(ex db.Execer, cfg config.Config, source, table string, columns []string, args ...any) (stored bool, err error) {
+	v, err := checkReading(ex, cfg, source, table, columns, args)
+	if err != nil {
+		return false, err
+	}
+	if v != nil {
+		return false, quarantine(ex, source, table, columns, args, v)
+	}
+
+	start := time.Now()
+	stored, err = db.InsertReading(ex, table, cfg.Dedup.OnConflictFor(source), columns, args...)
+	metrics.WriteLatency.Observe(time.Since(start).Seconds())
+
+	switch {
+	case errors.Is(err, db.ErrDuplicate):
+		log.Printf("[%s] Doppelter Messwert verworfen (%s)", source, table)
+		metrics.Duplicates.Inc(source)
+		metrics.Errors.Inc(source, "duplicate")
+		return false, nil
+	case err == nil && !stored:
+		metrics.Duplicates.Inc(source)
+		if cfg.Broker.SetDebug {
+			log.Printf("[%s] Doppelter Messwert ignoriert (%s)", source, table)
+		}
+	}
+	return stored, err
+}
+
+// retainedFilter entscheidet pro Subscription, ob eine retained Nachricht
+// Messwerte (verwerfbar) oder Geräte-Infos (immer verarbeiten) enthält
+type retainedFilter func(topic, payload string) (meta bool)
+
+// allMeta: Subscriptions nur für Geräte-Infos (z.B. Tasmota INFO/STATUS)
+func allMeta(string, string) bool { return true }
+
+// zigbeeMeta: Geräteliste und Status der Bridge
+func zigbeeMeta(topic, _ string) bool { return strings.Contains(topic, "/bridge/") }
+
+// solarMeta: Texte wie Name oder Firmware landen in solar_meta
+func solarMeta(_, payload string) bool {
+	_, err := strconv.ParseFloat(payload, 64)
+	return err != nil
+}
+
+// skipRetained meldet, ob eine retained Nachricht verworfen wird
+func skipRetained(cfg config.Config, m Message, meta retainedFilter) bool {
+	if !m.Retained {
+		return false
+	}
+	if !cfg.Dedup.SkipRetained || (meta != nil && meta(m.Topic, string(m.Payload))) {
+		if cfg.Broker.SetDebug {
+			log.Printf("Retained Nachricht: %s", m.Topic)
+		}
+		return false
+	}
+	metrics.RetainedSkipped.Inc(cfg.Broker.Name)
+	if cfg.Broker.SetDebug {
+		log.Printf("Retained Nachricht verworfen: %s", m.Topic)
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package mqtt
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+)
+
+// -------------------------------------------------------------------
+// Geräteregister: Anlage beim ersten Empfang, Tasmota INFO/STATE
+// -------------------------------------------------------------------
+
+// touchInterval: last_seen wird höchstens so oft pro Gerät geschrieben
+const touchInterval = time.Minute
+
+type touchKey struct {
+	db         *sql.DB
+	source, id string
+}
+
+// touchSpan ist der zuletzt geschriebene first/last-seen-Bereich
+type touchSpan struct{ first, last time.Time }
+
+var (
+	touchMu sync.Mutex
+	touched = map[touchKey]touchSpan{}
+)
+
+// touchDevice trägt das Gerät ins Register ein (first/last seen). Solange
+// ts im bereits geschriebenen Bereich liegt und last_seen jünger als
+// touchInterval ist, entfällt der Upsert.
+func touchDevice(dbh *sql.DB, source, id string, ts time.Time) {
+	k := touchKey{dbh, source, id}
+	touchMu.Lock()
+	span, ok := touched[k]
+	touchMu.Unlock()
+	if ok && !ts.Before(span.first) && ts.Sub(span.last) < touchInterval {
+		return
+	}
+
+	if err := db.TouchDevice(dbh, source, id, ts); err != nil {
+		log.Printf("[Geräte] DB-Fehler %s/%s: %v", source, id, err)
+		metrics.Errors.Inc(source, "db")
+		return
+	}
+
+	touchMu.Lock()
+	defer touchMu.Unlock()
+	span, ok = touched[k]
+	if !ok || ts.Before(span.first) {
+		span.first = ts
+	}
+	if ts.After(span.last) {
+		span.last = ts
+	}
+	touched[k] = span
+}
+
+// tasmotaMetaTopics leitet aus den SENSOR-Topics die Topics für STATE und
+// INFO1..3 ab (gleiches FullTopic, anderes letztes Segment)
+func tasmotaMetaTopics(topics []string) []string {
+	var out []string
+	for _, t := range topics {
+		base, ok := strings.CutSuffix(t, "/SENSOR")
+		if !ok {
+			continue
+		}
+		for _, suffix := range []string{"STATE", "INFO1", "INFO2", "INFO3"} {
+			out = append(out, base+"/"+suffix)
+		}
+	}
+	return out
+}
+
+// handleTasmotaMeta speichert STATE und INFO1..3 im Geräteregister
+func handleTasmotaMeta(topic, payload string, dbh *sql.DB, cfg config.Config) {
+	deviceID, ok, allowed := tasmotaDevice(cfg.Tasmota, topic)
+	if !ok || !allowed {
+		return
+	}
+	if !json.Valid([]byte(payload)) || !strings.HasPrefix(strings.TrimSpace(payload), "{") {
+		log.Printf("[Tasmota] Metadaten kein JSON-Objekt: %s", topic)
+		metrics.Errors.Inc("tasmota", "json")
+		return
+	}
+
+	var err error
+	if strings.HasSuffix(topic, "/STATE") {
+		err = db.SetDeviceState(dbh, "tasmota", deviceID, json.RawMessage(payload))
+	} else {
+		err = db.SetDeviceInfo(dbh, "tasmota", deviceID, json.RawMessage(payload))
+	}
+	if err != nil {
+		log.Printf("[Tasmota] DB-Fehler Metadaten %s: %v", deviceID, err)
+		metrics.Errors.Inc("tasmota", "db")
+		return
+	}
+	if cfg.Broker.SetDebug {
+		log.Printf("[Tasmota] Metadaten gespeichert: %s", topic)
+	}
+}
@@ -0,0 +1,155 @@
+package mqtt
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+)
+
+// -------------------------------------------------------------------
+// Home Assistant MQTT-Discovery
+// -------------------------------------------------------------------
+
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+type haSensor struct {
+	Name                string   `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	ObjectID            string   `json:"object_id"`
+	StateTopic          string   `json:"state_topic"`
+	ValueTemplate       string   `json:"value_template"`
+	Unit                string   `json:"unit_of_measurement,omitempty"`
+	DeviceClass         string   `json:"device_class,omitempty"`
+	StateClass          string   `json:"state_class,omitempty"`
+	AvailabilityTopic   string   `json:"availability_topic"`
+	PayloadAvailable    string   `json:"payload_available"`
+	PayloadNotAvailable string   `json:"payload_not_available"`
+	Device              haDevice `json:"device"`
+}
+
+var invalidObjectID = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+func haNames(cfg config.HomeAssistantConfig) (prefix, node string) {
+	prefix = strings.TrimSuffix(cfg.DiscoveryPrefix, "/")
+	if prefix == "" {
+		prefix = "homeassistant"
+	}
+	node = invalidObjectID.ReplaceAllString(cfg.NodeID, "_")
+	if node == "" {
+		node = "mqttlogger"
+	}
+	return prefix, node
+}
+
+// discoveryConfigs liefert Topic -> Sensor-Konfiguration.
+// Energie-Sensoren sind total_increasing (kWh), Kosten als monetary mit
+// state_class total, da Home Assistant für Geldbeträge nichts anderes erlaubt.
+func discoveryConfigs(cfg config.Config, devices []db.DeviceEnergy) map[string]haSensor {
+	prefix, node := haNames(cfg.HomeAssistant)
+	t := PublishTopics(cfg.Publish)
+	st := Status(cfg)
+	dev := haDevice{
+		Identifiers:  []string{node},
+		Name:         "mqttlogger",
+		Manufacturer: "mqttlogger",
+		Model:        "MQTT Energy Logger",
+	}
+
+	out := map[string]haSensor{}
+	add := func(id, name, topic, tpl, unit, devClass, stateClass string) {
+		out[prefix+"/sensor/"+node+"/"+id+"/config"] = haSensor{
+			Name:                name,
+			UniqueID:            node + "_" + id,
+			ObjectID:            node + "_" + id,
+			StateTopic:          topic,
+			ValueTemplate:       tpl,
+			Unit:                unit,
+			DeviceClass:         devClass,
+			StateClass:          stateClass,
+			AvailabilityTopic:   st.Topic,
+			PayloadAvailable:    st.Online,
+			PayloadNotAvailable: st.Offline,
+			Device:              dev,
+		}
+	}
+
+	add("energy_today", "Verbrauch heute", t.Today, "{{ value_json.consumption_kwh }}", "kWh", "energy", "total_increasing")
+	add("energy_month", "Verbrauch Monat", t.Month, "{{ value_json.consumption_kwh }}", "kWh", "energy", "total_increasing")
+	add("energy_year", "Verbrauch Jahr", t.Year, "{{ value_json.consumption_kwh }}", "kWh", "energy", "total_increasing")
+	add("cost_month", "Kosten Monat", t.Month, "{{ value_json.cost }}", "EUR", "monetary", "total")
+	add("cost_year", "Kosten Jahr", t.Year, "{{ value_json.cost }}", "EUR", "monetary", "total")
+	add("tariff", "Tarif", t.Tariff, "{{ value_json.per_kwh }}", "EUR/kWh", "", "")
+	add("grid_import_total", "Netzbezug gesamt", t.Meter, "{{ value_json.e_in }}", "kWh", "energy", "total_increasing")
+	add("grid_export_total", "Einspeisung gesamt", t.Meter, "{{ value_json.e_out }}", "kWh", "energy", "total_increasing")
+
+	for _, d := range devices {
+		id := "device_" + d.Source + "_" + invalidObjectID.ReplaceAllString(d.Device, "_") + "_energy"
+		add(id, "Energie "+d.Device, t.Device(d.Source, d.Device), "{{ value_json.total_kwh }}", "kWh", "energy", "total_increasing")
+	}
+	return out
+}
+
+var (
+	discoveredMu sync.Mutex
+	discovered   = map[string]bool{}
+)
+
+// PublishDiscovery veröffentlicht die Discovery-Konfigurationen. Mit
+// force=false werden nur noch nicht gemeldete Sensoren (neue Geräte) gesendet.
+func PublishDiscovery(client publisher, dbh *sql.DB, cfg config.Config, force bool) {
+	if !cfg.HomeAssistant.Enabled {
+		return
+	}
+
+	devices, err := db.LatestDeviceEnergy(dbh)
+	if err != nil {
+		log.Printf("[HA] DB-Fehler Geräte: %v", err)
+	}
+
+	discoveredMu.Lock()
+	defer discoveredMu.Unlock()
+
+	for topic, s := range discoveryConfigs(cfg, devices) {
+		if discovered[topic] && !force {
+			continue
+		}
+		payload, err := json.Marshal(s)
+		if err != nil {
+			log.Printf("[HA] JSON-Fehler: %v", err)
+			continue
+		}
+		publishRetained(client, topic, cfg.Publish.Qos, payload, primaryDebug(cfg))
+		discovered[topic] = true
+	}
+}
+
+// onHomeAssistantConnect sendet die Discovery und wiederholt sie, sobald
+// Home Assistant neu startet (Birth-Message auf <prefix>/status)
+func onHomeAssistantConnect(c Client, dbh *sql.DB, cfg config.Config) {
+	go PublishDiscovery(c, dbh, cfg, true)
+
+	prefix, _ := haNames(cfg.HomeAssistant)
+	err := c.Subscribe(Subscription{Topic: prefix + "/status", Handler: func(m Message) {
+		if string(m.Payload) != "online" {
+			return
+		}
+		go func() {
+			PublishDiscovery(c, dbh, cfg, true)
+			PublishAggregates(c, dbh, cfg)
+		}()
+	}})
+	if err != nil {
+		logSubscribeError(prefix+"/status", err)
+	}
+}
@@ -0,0 +1,76 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+)
+
+func TestDiscoveryConfigsForDerivedSensors(t *testing.T) {
+	cfg := config.Config{
+		Publish:       config.PublishConfig{TopicPrefix: "home"},
+		HomeAssistant: config.HomeAssistantConfig{Enabled: true, NodeID: "keller logger"},
+	}
+
+	configs := discoveryConfigs(cfg, []db.DeviceEnergy{
+		{Source: "tasmota", Device: "tasmota_A1.B2"},
+		{Source: "zigbee", Device: "tasmota_A1.B2"},
+		{Source: "zigbee", Device: "Keller/Gefrier+truhe#1"},
+	})
+
+	today, ok := configs["homeassistant/sensor/keller_logger/energy_today/config"]
+	if !ok {
+		t.Fatalf("missing energy_today config, got %v", keys(configs))
+	}
+	if today.StateTopic != "home/energy/today" || today.StateClass != "total_increasing" ||
+		today.DeviceClass != "energy" || today.Unit != "kWh" {
+		t.Fatalf("unexpected energy_today config: %+v", today)
+	}
+	if today.AvailabilityTopic != "home/status" || today.PayloadNotAvailable != "offline" {
+		t.Fatalf("availability not tied to status topic: %+v", today)
+	}
+
+	feedIn := configs["homeassistant/sensor/keller_logger/grid_export_total/config"]
+	if feedIn.ValueTemplate != "{{ value_json.e_out }}" || feedIn.StateTopic != "home/energy/meter" {
+		t.Fatalf("unexpected feed-in config: %+v", feedIn)
+	}
+
+	cost := configs["homeassistant/sensor/keller_logger/cost_month/config"]
+	if cost.DeviceClass != "monetary" || cost.StateClass != "total" {
+		t.Fatalf("unexpected cost config: %+v", cost)
+	}
+
+	plug, ok := configs["homeassistant/sensor/keller_logger/device_tasmota_tasmota_A1_B2_energy/config"]
+	if !ok {
+		t.Fatalf("missing device config, got %v", keys(configs))
+	}
+	if plug.StateTopic != "home/device/tasmota/tasmota_A1.B2/energy" || plug.StateClass != "total_increasing" {
+		t.Fatalf("unexpected device config: %+v", plug)
+	}
+
+	// gleiche ID bei Zigbee: eigenes Topic und eigene unique_id
+	twin := configs["homeassistant/sensor/keller_logger/device_zigbee_tasmota_A1_B2_energy/config"]
+	if twin.StateTopic != "home/device/zigbee/tasmota_A1.B2/energy" || twin.UniqueID == plug.UniqueID {
+		t.Fatalf("zigbee twin collides: %+v", twin)
+	}
+
+	// friendly_name mit / + # ergibt genau eine Topic-Ebene ohne Wildcards
+	freezer := configs["homeassistant/sensor/keller_logger/device_zigbee_Keller_Gefrier_truhe_1_energy/config"]
+	if freezer.StateTopic != "home/device/zigbee/Keller_Gefrier_truhe_1/energy" {
+		t.Fatalf("unexpected zigbee topic: %+v", freezer)
+	}
+
+	if _, err := json.Marshal(plug); err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+}
+
+func keys(m map[string]haSensor) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
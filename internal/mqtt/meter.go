@@ -0,0 +1,72 @@
+package mqtt
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/health"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+	"github.com/khorsmann/mqttlogger/internal/sml"
+	"github.com/khorsmann/mqttlogger/internal/stream"
+)
+
+// -------------------------------------------------------------------
+// Zähler mit IR-Lesekopf: rohes SML (binär oder Hex) bzw. D0-Text
+// -------------------------------------------------------------------
+
+// meterID liefert die Zählernummer aus dem Telegramm, sonst das Topic-
+// Segment vor dem letzten (z.B. "tele/<lesekopf>/SML")
+func meterID(r sml.Reading, topic string) string {
+	if r.MeterID != "" {
+		return r.MeterID
+	}
+	seg := strings.Split(topic, "/")
+	if len(seg) >= 2 {
+		return seg[len(seg)-2]
+	}
+	return "sml"
+}
+
+func handleSML(topic, payload string, db *sql.DB, cfg config.Config) {
+	metrics.MessagesReceived.Inc("sml")
+
+	r, err := sml.Decode([]byte(payload))
+	if err != nil {
+		log.Printf("[SML] %s: %v", topic, err)
+		metrics.Errors.Inc("sml", "decode")
+		return
+	}
+	meter := meterID(r, topic)
+	now := time.Now()
+
+	stored, err := storeReading(db, cfg, "sml", "meter_data",
+		[]string{"meter_id", "timestamp_unix", "timestamp_rfc3339", "e_in", "e_out", "power"},
+		meter, now.Unix(), now.Format(time.RFC3339), r.EIn, r.EOut, r.Power)
+	if err != nil {
+		log.Printf("[SML] DB Insert Fehler: %v", err)
+		metrics.Errors.Inc("sml", "db")
+		return
+	}
+	if !stored {
+		return
+	}
+
+	metrics.MessagesStored.Inc("sml")
+	health.Default.Seen("sml")
+	touchDevice(db, "sml", meter, now)
+	for metric, v := range map[string]*float64{"e_in": r.EIn, "e_out": r.EOut, "power": r.Power} {
+		if v == nil {
+			continue
+		}
+		if metric == "power" {
+			metrics.MeterPower.Set(*v, meter)
+		}
+		stream.Default.Publish(stream.Reading{Source: "sml", Device: meter, Metric: metric, Value: *v, Time: now})
+	}
+	if cfg.Broker.SetDebug {
+		log.Printf("[SML] %s: %d OBIS-Werte gespeichert", meter, len(r.Values))
+	}
+}
@@ -0,0 +1,50 @@
+package mqtt
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+func TestHandleSMLStoresMeterReadings(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	bin, err := os.ReadFile("../sml/testdata/ehz_getlist.bin")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	handleSML("tele/keller/SML", string(bin), db, config.Config{})
+	handleSML("tele/garage/SML", "/LGZ5\r\n1.8.0(000100.500*kWh)\r\n16.7.0(0.250*kW)\r\n!\r\n", db, config.Config{})
+	handleSML("tele/garage/SML", "1b1b1b1b01010101 kaputt", db, config.Config{})
+
+	rows, err := db.Query(`SELECT meter_id, e_in, power FROM meter_data ORDER BY id`)
+	if err != nil {
+		t.Fatalf("select meter_data: %v", err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		meter      string
+		eIn, power float64
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.meter, &r.eIn, &r.power); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, r)
+	}
+	want := []row{{"0a01454d4800009f4b29", 12345.6789, 456}, {"garage", 100.5, 250}}
+	if len(got) != len(want) {
+		t.Fatalf("rows = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].meter != want[i].meter || got[i].power != want[i].power || math.Abs(got[i].eIn-want[i].eIn) > 1e-6 {
+			t.Fatalf("row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
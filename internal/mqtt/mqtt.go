@@ -3,50 +3,137 @@ package mqtt
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"strconv"
 	"strings"
 	"time"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/health"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+	"github.com/khorsmann/mqttlogger/internal/stream"
 )
 
-// StartClient startet den MQTT-Client und registriert die Handler
-func StartClient(cfg config.Config, db *sql.DB) mqtt.Client {
-	opts := mqtt.NewClientOptions().
-		AddBroker(cfg.Broker.Host).
-		SetClientID(cfg.Broker.ClientID).
-		SetUsername(cfg.Broker.Username).
-		SetPassword(cfg.Broker.Password)
+// StartClient verbindet alle konfigurierten Broker und registriert die
+// Handler. Zurückgegeben wird der erste Broker, über den Status,
+// Aggregate und Home-Assistant-Discovery veröffentlicht werden.
+func StartClient(cfg config.Config, db *sql.DB) Client {
+	var primary Client
+	for i, b := range cfg.BrokerList() {
+		client := startBroker(cfg, b, db, i == 0)
+		if i == 0 {
+			primary = client
+		}
+	}
 
-	client := mqtt.NewClient(opts)
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		log.Fatalf("MQTT Verbindung fehlgeschlagen: %v", token.Error())
+	if statusEnabled(cfg) {
+		startHeartbeat(primary, cfg)
 	}
+	return primary
+}
 
-	// Subscription & Handler
-	client.Subscribe(cfg.Topics.Wattwaechter, cfg.Broker.Qos, func(c mqtt.Client, m mqtt.Message) {
-		handleWattwaechter(m.Topic(), string(m.Payload()), db, cfg)
-	})
+// startBroker verbindet einen Broker; nur der primäre meldet Status und Discovery
+func startBroker(cfg config.Config, b config.BrokerConfig, db *sql.DB, primary bool) Client {
+	status := Status(cfg)
 
-	client.Subscribe(cfg.Topics.Tasmota, cfg.Broker.Qos, func(c mqtt.Client, m mqtt.Message) {
-		handleTasmota(m.Topic(), string(m.Payload()), db, cfg)
-	})
+	// Handler sehen die Einstellungen (z.B. debug) ihres Brokers
+	bcfg := cfg
+	bcfg.Broker = b
 
-	if cfg.Features.SolarEnabled {
-		client.Subscribe("solar/#", cfg.Broker.Qos, func(c mqtt.Client, m mqtt.Message) {
-			handleSolar(m.Topic(), string(m.Payload()), db, cfg)
-		})
+	var subs []Subscription
+	add := func(topic string, handle func(topic, payload string, db *sql.DB, cfg config.Config), meta retainedFilter) {
+		if topic == "" {
+			return
+		}
+		subs = append(subs, Subscription{Topic: sharedTopic(b.SharedGroup, topic), Qos: b.Qos, Handler: func(m Message) {
+			if skipRetained(bcfg, m, meta) {
+				return
+			}
+			handle(m.Topic, string(m.Payload), db, bcfg)
+		}})
+	}
+	add(b.Topics.Wattwaechter, handleWattwaechter, nil)
+	for _, topic := range b.Topics.Tasmota {
+		add(topic, handleTasmota, nil)
+	}
+	for _, topic := range tasmotaMetaTopics(b.Topics.Tasmota) {
+		add(topic, handleTasmotaMeta, allMeta)
 	}
+	for _, topic := range b.Topics.Shelly {
+		add(topic, handleShelly, nil)
+	}
+	add(b.Topics.Zigbee2MQTT, handleZigbee, zigbeeMeta)
+	for _, topic := range b.Topics.SML {
+		add(topic, handleSML, nil)
+	}
+	add(b.Topics.Solar, handleSolar, solarMeta)
+
+	opts := clientOptions{
+		Broker:        b,
+		ClientID:      b.ClientID,
+		Subscriptions: subs,
+		AutoReconnect: true,
+		OnConnect: func(c Client) {
+			metrics.MQTTConnected.Set(1, b.Name)
+			health.Default.SetConnected(b.Name, true)
+			log.Printf("MQTT verbunden: %s (%s)", b.Name, b.Host)
+			if !primary {
+				return
+			}
+			if statusEnabled(cfg) {
+				publishRetained(c, status.Topic, cfg.Publish.Qos, []byte(status.Online), b.SetDebug)
+			}
+			if cfg.HomeAssistant.Enabled {
+				onHomeAssistantConnect(c, db, cfg)
+			}
+		},
+		OnLost: func(err error) {
+			metrics.MQTTConnected.Set(0, b.Name)
+			health.Default.SetConnected(b.Name, false)
+			log.Printf("MQTT Verbindung verloren (%s): %v", b.Name, err)
+		},
+	}
+
+	// Bei Verbindungsabbruch meldet der Broker "offline" (Last Will)
+	if primary && statusEnabled(cfg) {
+		opts.Will = &will{Topic: status.Topic, Payload: status.Offline, Qos: cfg.Publish.Qos}
+	}
+
+	// bis zum ersten Verbindungsaufbau gilt der Broker als getrennt
+	metrics.MQTTConnected.Set(0, b.Name)
+	health.Default.SetConnected(b.Name, false)
 
+	client, err := connect(opts)
+	if err != nil {
+		// nur der primäre Broker ist Pflicht; weitere verbinden sich nachträglich
+		if client == nil || primary {
+			log.Fatalf("MQTT Verbindung zu %s fehlgeschlagen: %v", b.Name, err)
+		}
+		log.Printf("MQTT Verbindung zu %s fehlgeschlagen, neuer Versuch im Hintergrund: %v", b.Name, err)
+	}
 	return client
 }
 
+// Probe baut testweise eine Verbindung zum Broker auf (für "mqttlogger health")
+func Probe(b config.BrokerConfig, timeout time.Duration) error {
+	client, err := connect(clientOptions{
+		Broker:         b,
+		ClientID:       b.ClientID + "-health",
+		ConnectTimeout: timeout,
+	})
+	if err != nil {
+		return err
+	}
+	client.Disconnect()
+	return nil
+}
+
 // ---------------- Handler Stubs ----------------
 
 func handleWattwaechter(topic, payload string, db *sql.DB, cfg config.Config) {
 	log.Printf("[Wattwaechter] %s = %s", topic, payload)
+	metrics.MessagesReceived.Inc("wattwaechter")
 
 	// Struct für JSON
 	type E320 struct {
@@ -64,121 +151,218 @@ func handleWattwaechter(topic, payload string, db *sql.DB, cfg config.Config) {
 	var msg Msg
 	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
 		log.Printf("[Wattwaechter] JSON-Fehler: %v", err)
+		metrics.Errors.Inc("wattwaechter", "json")
 		return
 	}
 
-	// --- ZEIT PARSEN ----------------------------------------------
-
-	var t time.Time
-	var err error
+	// Drehstrom- und Tarifwerte, soweit der Lesekopf sie liefert
+	var raw struct {
+		E320 map[string]json.RawMessage `json:"E320"`
+	}
+	_ = json.Unmarshal([]byte(payload), &raw)
+	extras := wattwaechterExtras(raw.E320, cfg.Wattwaechter.Fields)
 
-	// 1) Versuch: RFC3339 (falls Tasmota eine Zeitzone mitschickt)
-	t, err = time.Parse(time.RFC3339, msg.Time)
+	// --- ZEIT ---------------------------------------------------
 
-	if err != nil {
-		// 2) Versuch: Ohne Zeitzone
-		t2, err2 := time.Parse("2006-01-02T15:04:05", msg.Time)
-		if err2 != nil {
-			log.Printf("[Wattwaechter] Zeitformatfehler: %v", err2)
-			// Fallback: jetzt (lokale Zeit)
-			t = time.Now()
-		} else {
-			// Zeitzone hinzufügen
-			loc, locErr := time.LoadLocation(cfg.Time.Timezone)
-			if locErr != nil {
-				loc = time.Local
-			}
-
-			t = time.Date(
-				t2.Year(), t2.Month(), t2.Day(),
-				t2.Hour(), t2.Minute(), t2.Second(),
-				t2.Nanosecond(),
-				loc,
-			)
-		}
+	device := msg.E320.MeterNumber
+	if device == "" {
+		device = "wattwaechter"
 	}
+	t := resolveTime(cfg, "wattwaechter", device, deviceTime(cfg, "wattwaechter", msg.Time), time.Now())
+	t = t.In(cfg.Time.Location())
 
 	tUnix := t.Unix()
 	tRFC := t.Format(time.RFC3339)
 
 	// --- DB INSERT --------------------------------------------------
 
-	stmt, err := db.Prepare(`
-        INSERT INTO energy_data (timestamp_unix, timestamp_rfc3339, e_in, e_out, power)
-        VALUES (?, ?, ?, ?, ?)
-    `)
-	if err != nil {
-		log.Printf("[Wattwaechter] DB-Prepare-Fehler: %v", err)
-		return
+	cols := []string{"timestamp_unix", "timestamp_rfc3339", "e_in", "e_out", "power"}
+	args := []any{tUnix, tRFC, msg.E320.EIn, msg.E320.EOut, msg.E320.Power}
+	for _, c := range wattwaechterColumns {
+		var v any
+		if x, ok := extras[c]; ok {
+			v = x
+		}
+		cols = append(cols, c)
+		args = append(args, v)
 	}
-	defer stmt.Close()
-
-	_, err = stmt.Exec(
-		tUnix,
-		tRFC,
-		msg.E320.EIn,
-		msg.E320.EOut,
-		msg.E320.Power,
-	)
 
+	stored, err := storeReading(db, cfg, "wattwaechter", "energy_data", cols, args...)
 	if err != nil {
 		log.Printf("[Wattwaechter] DB-Insert-Fehler: %v", err)
+		metrics.Errors.Inc("wattwaechter", "db")
+		return
+	}
+	if !stored {
 		return
 	}
 
+	metrics.MessagesStored.Inc("wattwaechter")
+	health.Default.Seen("wattwaechter")
+	metrics.Power.Set(msg.E320.Power)
+	metrics.EnergyIn.Set(msg.E320.EIn)
+	metrics.EnergyOut.Set(msg.E320.EOut)
+	for i, c := range []string{"power_l1", "power_l2", "power_l3"} {
+		if v, ok := extras[c]; ok {
+			metrics.PhasePower.Set(v, strconv.Itoa(i+1))
+		}
+	}
+
+	readings := []stream.Reading{
+		{Metric: "e_in", Value: msg.E320.EIn},
+		{Metric: "e_out", Value: msg.E320.EOut},
+		{Metric: "power", Value: msg.E320.Power},
+	}
+	for _, c := range wattwaechterColumns {
+		if v, ok := extras[c]; ok {
+			readings = append(readings, stream.Reading{Metric: c, Value: v})
+		}
+	}
+	for _, r := range readings {
+		r.Source, r.Device, r.Time = "wattwaechter", device, t
+		stream.Default.Publish(r)
+	}
+
 	if cfg.Broker.SetDebug {
 		log.Printf(
-			"[Wattwaechter] gespeichert ts=%s Ein=%f Eout=%f Power=%f",
-			tRFC, msg.E320.EIn, msg.E320.EOut, msg.E320.Power,
+			"[Wattwaechter] gespeichert ts=%s Ein=%f Eout=%f Power=%f Zusatz=%v",
+			tRFC, msg.E320.EIn, msg.E320.EOut, msg.E320.Power, extras,
 		)
 	}
 }
 
 func handleTasmota(topic, payload string, db *sql.DB, cfg config.Config) {
 	log.Printf("[Tasmota] %s = %s", topic, payload)
+	metrics.MessagesReceived.Inc("tasmota")
+
+	deviceID, ok, allowed := tasmotaDevice(cfg.Tasmota, topic)
+	if !ok {
+		log.Printf("[Tasmota] Topic passt nicht zu full_topic: %s", topic)
+		metrics.Errors.Inc("tasmota", "topic")
+		return
+	}
+	if !allowed {
+		if cfg.Broker.SetDebug {
+			log.Printf("[Tasmota] Gerät %s ignoriert (allow/deny)", deviceID)
+		}
+		return
+	}
 
 	var msg struct {
 		Time   string `json:"Time"`
 		Energy struct {
-			Power float64 `json:"Power"`
+			Power float64  `json:"Power"`
+			Total *float64 `json:"Total"`
 		} `json:"ENERGY"`
 	}
 
 	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
 		log.Printf("[Tasmota] JSON Fehler: %v", err)
+		metrics.Errors.Inc("tasmota", "json")
 		return
 	}
 
-	t, err := time.Parse(time.RFC3339, msg.Time)
+	t := resolveTime(cfg, "tasmota", deviceID, deviceTime(cfg, "tasmota", msg.Time), time.Now())
+	t = t.In(cfg.Time.Location())
+
+	stored, err := storeReading(db, cfg, "tasmota", "tasmota_data",
+		[]string{"device_id", "timestamp_unix", "timestamp_rfc3339", "power", "energy_total"},
+		deviceID, t.Unix(), t.Format(time.RFC3339), msg.Energy.Power, msg.Energy.Total)
 	if err != nil {
-		log.Printf("[Tasmota] Zeitformatfehler: %v", err)
-		t = time.Now()
+		log.Printf("[Tasmota] DB Insert Fehler: %v", err)
+		metrics.Errors.Inc("tasmota", "db")
+		return
+	}
+	if !stored {
+		return
 	}
 
-	stmt, err := db.Prepare(`
-		INSERT INTO tasmota_data (device_id, timestamp_unix, timestamp_rfc3339, power)
-		VALUES (?, ?, ?, ?)
-	`)
+	metrics.MessagesStored.Inc("tasmota")
+	health.Default.Seen("tasmota")
+	touchDevice(db, "tasmota", deviceID, t)
+	metrics.TasmotaPower.Set(msg.Energy.Power, deviceID)
+	stream.Default.Publish(stream.Reading{Source: "tasmota", Device: deviceID, Metric: "power", Value: msg.Energy.Power, Time: t})
+}
+
+func handleShelly(topic, payload string, db *sql.DB, cfg config.Config) {
+	msg, err := decodeShelly(topic, payload)
+	if errors.Is(err, errShellyTopic) {
+		// shellies/# enthält auch announce, online, input, ...
+		if cfg.Broker.SetDebug {
+			log.Printf("[Shelly] Topic ignoriert: %s", topic)
+		}
+		return
+	}
+	metrics.MessagesReceived.Inc("shelly")
 	if err != nil {
-		log.Printf("[Tasmota] DB Prepare Fehler: %v", err)
+		log.Printf("[Shelly] Fehler in %s: %v", topic, err)
+		metrics.Errors.Inc("shelly", "json")
+		return
+	}
+	if len(msg.Readings) == 0 {
 		return
 	}
-	defer stmt.Close()
 
-	deviceID := strings.Split(topic, "/")[1]
+	t := resolveTime(cfg, "shelly", msg.Device, msg.Time, time.Now())
+	t = t.In(cfg.Time.Location())
 
-	_, err = stmt.Exec(deviceID, t.Unix(), msg.Time, msg.Energy.Power)
+	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("[Tasmota] DB Insert Fehler: %v", err)
+		log.Printf("[Shelly] DB Fehler: %v", err)
+		metrics.Errors.Inc("shelly", "db")
+		return
+	}
+	defer tx.Rollback()
+	stored := false
+	for _, r := range msg.Readings {
+		ok, err := storeReading(tx, cfg, "shelly", "shelly_data",
+			[]string{"device_id", "channel", "timestamp_unix", "timestamp_rfc3339", "power", "voltage", "current", "energy_total", "energy_returned"},
+			msg.Device, r.Channel, t.Unix(), t.Format(time.RFC3339), r.Power, r.Voltage, r.Current, r.Energy, r.Returned)
+		if err != nil {
+			log.Printf("[Shelly] DB Insert Fehler: %v", err)
+			metrics.Errors.Inc("shelly", "db")
+			return
+		}
+		stored = stored || ok
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[Shelly] DB Commit Fehler: %v", err)
+		metrics.Errors.Inc("shelly", "db")
+		return
+	}
+	if !stored {
+		return
+	}
+
+	metrics.MessagesStored.Inc("shelly")
+	health.Default.Seen("shelly")
+	touchDevice(db, "shelly", msg.Device, t)
+	for _, r := range msg.Readings {
+		ch := strconv.Itoa(r.Channel)
+		for metric, v := range map[string]*float64{
+			"power": r.Power, "voltage": r.Voltage, "current": r.Current,
+			"energy_total": r.Energy, "energy_returned": r.Returned,
+		} {
+			if v == nil {
+				continue
+			}
+			if metric == "power" {
+				metrics.ShellyPower.Set(*v, msg.Device, ch)
+			}
+			stream.Default.Publish(stream.Reading{Source: "shelly", Device: msg.Device, Metric: ch + "/" + metric, Value: *v, Time: t})
+		}
+	}
+	if cfg.Broker.SetDebug {
+		log.Printf("[Shelly] %s: %d Kanäle gespeichert", msg.Device, len(msg.Readings))
 	}
 }
 
 func handleSolar(topic, payload string, db *sql.DB, cfg config.Config) {
-	loc, err := time.LoadLocation(cfg.Time.Timezone)
-	if err != nil {
-		loc = time.UTC
-	}
-	now := time.Now().In(loc)
+	metrics.MessagesReceived.Inc("solar")
+
+	// Werte kommen ohne Zeitstempel; status/last_update (Unix-Zeit) dient
+	// nur der Prüfung der Geräte-Uhr
+	now := time.Now().In(cfg.Time.Location())
 	rfc3339Time := now.Format(time.RFC3339)
 	unixTime := now.Unix()
 	segments := strings.Split(topic, "/")
@@ -192,27 +376,42 @@ func handleSolar(topic, payload string, db *sql.DB, cfg config.Config) {
 	channel := -1
 	metric := strings.Join(segments[2:], "/")
 
+	if metric == "status/last_update" {
+		if dev, err := parseDeviceTime(config.TimeConfig{InputFormat: "unix"}, payload); err == nil {
+			checkSkew(cfg.Time.PolicyFor("solar"), "solar", deviceID, dev, now)
+		}
+	}
+
 	if val, err := strconv.ParseFloat(payload, 64); err == nil {
-		stmt, _ := db.Prepare(`INSERT INTO solar_data (timestamp_unix, timestamp_rfc3339, device_id, channel, metric, value) VALUES (?, ?, ?, ?, ?, ?)`)
-		defer stmt.Close()
-		_, err = stmt.Exec(unixTime, rfc3339Time, deviceID, channel, metric, val)
+		stored, err := storeReading(db, cfg, "solar", "solar_data",
+			[]string{"timestamp_unix", "timestamp_rfc3339", "device_id", "channel", "metric", "value"},
+			unixTime, rfc3339Time, deviceID, channel, metric, val)
 		if err != nil {
 			log.Printf("[Solar] DB Fehler solar_data: %v", err)
-		} else if cfg.Broker.SetDebug {
+			metrics.Errors.Inc("solar", "db")
+			return
+		}
+		if !stored {
+			return
+		}
+		metrics.MessagesStored.Inc("solar")
+		health.Default.Seen("solar")
+		touchDevice(db, "solar", deviceID, now)
+		stream.Default.Publish(stream.Reading{Source: "solar", Device: deviceID, Metric: metric, Value: val, Time: now})
+		if cfg.Broker.SetDebug {
 			log.Printf("[Solar] %s/%d/%s = %f", deviceID, channel, metric, val)
 		}
 		return
 	}
 
-	stmt, _ := db.Prepare(`
+	_, err := db.Exec(`
 		INSERT INTO solar_meta (device_id, channel, key, value)
 		VALUES (?, ?, ?, ?)
 		ON CONFLICT(device_id, channel, key) DO UPDATE SET value = excluded.value
-	`)
-	defer stmt.Close()
-	_, err = stmt.Exec(deviceID, channel, metric, payload)
+	`, deviceID, channel, metric, payload)
 	if err != nil {
 		log.Printf("[Solar] DB Fehler solar_meta: %v", err)
+		metrics.Errors.Inc("solar", "db")
 	} else if cfg.Broker.SetDebug {
 		log.Printf("[Solar] Meta gespeichert: %s/%d/%s = %s", deviceID, channel, metric, payload)
 	}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -22,14 +23,81 @@ func newMQTTTestDB(t *testing.T) *sql.DB {
 			timestamp_rfc3339 TEXT,
 			e_in REAL,
 			e_out REAL,
-			power INTEGER
+			power INTEGER,
+			power_l1 REAL, power_l2 REAL, power_l3 REAL,
+			voltage_l1 REAL, voltage_l2 REAL, voltage_l3 REAL,
+			current_l1 REAL, current_l2 REAL, current_l3 REAL,
+			frequency REAL,
+			e_in_t1 REAL, e_in_t2 REAL, e_out_t1 REAL, e_out_t2 REAL,
+			UNIQUE(timestamp_unix)
 		);`,
 		`CREATE TABLE tasmota_data (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			device_id TEXT,
 			timestamp_unix INTEGER,
 			timestamp_rfc3339 TEXT,
-			power INTEGER
+			power INTEGER,
+			energy_total REAL,
+			UNIQUE(device_id, timestamp_unix)
+		);`,
+		`CREATE TABLE shelly_data (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id TEXT,
+			channel INTEGER,
+			timestamp_unix INTEGER,
+			timestamp_rfc3339 TEXT,
+			power REAL,
+			voltage REAL,
+			current REAL,
+			energy_total REAL,
+			energy_returned REAL,
+			UNIQUE(device_id, channel, timestamp_unix)
+		);`,
+		`CREATE TABLE zigbee_data (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id TEXT,
+			timestamp_unix INTEGER,
+			timestamp_rfc3339 TEXT,
+			power REAL,
+			energy_total REAL,
+			voltage REAL,
+			current REAL,
+			UNIQUE(device_id, timestamp_unix)
+		);`,
+		`CREATE TABLE meter_data (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			meter_id TEXT,
+			timestamp_unix INTEGER,
+			timestamp_rfc3339 TEXT,
+			e_in REAL,
+			e_out REAL,
+			power REAL,
+			UNIQUE(meter_id, timestamp_unix)
+		);`,
+		`CREATE TABLE quarantine (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			received_unix INTEGER,
+			source TEXT,
+			device_id TEXT,
+			target_table TEXT,
+			timestamp_unix INTEGER,
+			metric TEXT,
+			value REAL,
+			reason TEXT,
+			row_json TEXT
+		);`,
+		`CREATE TABLE devices (
+			source TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			friendly_name TEXT,
+			room TEXT,
+			category TEXT,
+			nominal_power REAL,
+			first_seen INTEGER,
+			last_seen INTEGER,
+			info TEXT,
+			state TEXT,
+			PRIMARY KEY (source, device_id)
 		);`,
 	}
 	for _, stmt := range schema {
@@ -93,3 +161,50 @@ func TestHandleTasmotaPersistsPower(t *testing.T) {
 		t.Fatalf("unexpected values deviceID=%s power=%v", deviceID, power)
 	}
 }
+
+func TestHandleTasmotaIgnoresRedelivery(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	cfg := config.Config{Time: config.TimeConfig{Timezone: "Europe/Berlin"}}
+	payload := `{"Time":"2025-11-24T19:00:00Z","ENERGY":{"Power":42}}`
+	before := metrics.Duplicates.Value("tasmota")
+
+	handleTasmota("tele/dup/SENSOR", payload, db, cfg)
+	handleTasmota("tele/dup/SENSOR", payload, db, cfg)
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tasmota_data WHERE device_id = 'dup'`).Scan(&count); err != nil {
+		t.Fatalf("select tasmota_data: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 row, got %d", count)
+	}
+	if got := metrics.Duplicates.Value("tasmota") - before; got != 1 {
+		t.Fatalf("duplicates = %v, want 1", got)
+	}
+}
+
+func TestSkipRetained(t *testing.T) {
+	cfg := config.Config{Dedup: config.DedupConfig{SkipRetained: true}}
+	reading := Message{Topic: "tele/plug/SENSOR", Payload: []byte(`{}`), Retained: true}
+
+	if !skipRetained(cfg, reading, nil) {
+		t.Fatal("retained reading should be skipped")
+	}
+	if skipRetained(cfg, Message{Topic: "tele/plug/SENSOR"}, nil) {
+		t.Fatal("live message skipped")
+	}
+	if skipRetained(cfg, Message{Topic: "zigbee2mqtt/bridge/devices", Retained: true}, zigbeeMeta) {
+		t.Fatal("bridge devices skipped")
+	}
+	if skipRetained(cfg, Message{Topic: "solar/123/name", Payload: []byte("HM-800"), Retained: true}, solarMeta) {
+		t.Fatal("solar name skipped")
+	}
+	if !skipRetained(cfg, Message{Topic: "solar/123/0/power", Payload: []byte("350"), Retained: true}, solarMeta) {
+		t.Fatal("solar power kept")
+	}
+	if skipRetained(config.Config{}, reading, nil) {
+		t.Fatal("skip_retained is off by default")
+	}
+}
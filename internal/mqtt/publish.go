@@ -0,0 +1,136 @@
+package mqtt
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+)
+
+// Topics sind die Ziel-Topics der Veröffentlichung
+type Topics struct {
+	Prefix string
+	Today  string
+	Month  string
+	Year   string
+	Tariff string
+	Meter  string
+}
+
+// Device liefert das Topic für den Energiezähler eines Geräts (Tasmota,
+// Shelly, Zigbee2MQTT); die Quelle trennt gleichnamige Geräte
+func (t Topics) Device(source, id string) string {
+	return t.Prefix + "/device/" + source + "/" + topicLevel(id) + "/energy"
+}
+
+// topicLevel macht aus einer Geräte-ID eine einzelne Topic-Ebene: "/"
+// (z.B. im Zigbee2MQTT friendly_name) und die Wildcards + und # werden zu "_"
+func topicLevel(id string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(id)
+}
+
+// PublishTopics liefert die Ziel-Topics; leere Einträge werden aus dem
+// Präfix (Standard "mqttlogger") abgeleitet
+func PublishTopics(p config.PublishConfig) Topics {
+	prefix := strings.TrimSuffix(p.TopicPrefix, "/")
+	if prefix == "" {
+		prefix = "mqttlogger"
+	}
+	pick := func(v, suffix string) string {
+		if v != "" {
+			return v
+		}
+		return prefix + "/" + suffix
+	}
+	return Topics{
+		Prefix: prefix,
+		Today:  pick(p.Today, "energy/today"),
+		Month:  pick(p.Month, "energy/month"),
+		Year:   pick(p.Year, "energy/year"),
+		Tariff: pick(p.Tariff, "tariff"),
+		Meter:  prefix + "/energy/meter",
+	}
+}
+
+// publishEnabled: Home Assistant braucht die Zustands-Topics ebenfalls
+func publishEnabled(cfg config.Config) bool {
+	return cfg.Publish.Enabled || cfg.HomeAssistant.Enabled
+}
+
+// PublishAggregates veröffentlicht Tagesverbrauch, Monats- und Jahreskosten,
+// Tarif, Zählerstände und Geräte-Energiezähler als retained JSON
+func PublishAggregates(client publisher, dbh *sql.DB, cfg config.Config) {
+	if !publishEnabled(cfg) {
+		return
+	}
+
+	s, err := db.CurrentSummary(dbh, time.Now().In(cfg.Time.Location()), cfg.Cost.PerKWh)
+	if err != nil {
+		log.Printf("[Publish] DB-Fehler: %v", err)
+		return
+	}
+
+	t := PublishTopics(cfg.Publish)
+	messages := map[string]any{
+		t.Today: map[string]any{
+			"day":             s.Day,
+			"consumption_kwh": s.DayConsumption,
+			"cost":            s.DayConsumption * s.PerKWh,
+		},
+		t.Month: map[string]any{
+			"month":           s.Month,
+			"consumption_kwh": s.MonthConsumption,
+			"cost":            s.MonthCost,
+		},
+		t.Year: map[string]any{
+			"year":            s.Year,
+			"consumption_kwh": s.YearConsumption,
+			"cost":            s.YearCost,
+		},
+		t.Tariff: map[string]any{
+			"per_kwh": s.PerKWh,
+		},
+	}
+
+	if m, ok, err := db.LatestMeter(dbh); err != nil {
+		log.Printf("[Publish] DB-Fehler Zählerstand: %v", err)
+	} else if ok {
+		messages[t.Meter] = m
+	}
+
+	devices, err := db.LatestDeviceEnergy(dbh)
+	if err != nil {
+		log.Printf("[Publish] DB-Fehler Geräte: %v", err)
+	}
+	for _, d := range devices {
+		messages[t.Device(d.Source, d.Device)] = d
+	}
+
+	for topic, msg := range messages {
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("[Publish] JSON-Fehler: %v", err)
+			continue
+		}
+		publishRetained(client, topic, cfg.Publish.Qos, payload, primaryDebug(cfg))
+	}
+}
+
+// primaryDebug liefert die debug-Einstellung des Brokers, über den veröffentlicht wird
+func primaryDebug(cfg config.Config) bool {
+	return cfg.BrokerList()[0].SetDebug
+}
+
+func publishRetained(client publisher, topic string, qos byte, payload []byte, debug bool) {
+	if err := client.Publish(topic, qos, true, payload); err != nil {
+		log.Printf("[Publish] Fehler bei %s: %v", topic, err)
+		return
+	}
+	if debug {
+		log.Printf("[Publish] %s = %s", topic, payload)
+	}
+}
@@ -0,0 +1,78 @@
+package mqtt
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type published struct {
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+type fakePublisher map[string]published
+
+func (f fakePublisher) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	f[topic] = published{qos: qos, retained: retained, payload: payload}
+	return nil
+}
+
+func TestPublishAggregatesSendsRetainedJSON(t *testing.T) {
+	dbh, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer dbh.Close()
+	dbh.SetMaxOpenConns(1)
+	if err := db.CreateSchema(dbh); err != nil {
+		t.Fatalf("CreateSchema: %v", err)
+	}
+
+	cfg := config.Config{
+		Cost:    config.CostConfig{PerKWh: 0.5},
+		Publish: config.PublishConfig{Enabled: true, TopicPrefix: "home/", Year: "custom/year", Qos: 1},
+	}
+
+	// zwei Messwerte heute -> 4 kWh Tagesverbrauch
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 1, 0, time.UTC)
+	for i, eIn := range []float64{100, 104} {
+		ts := start.Add(time.Duration(i) * time.Second)
+		if _, err := dbh.Exec(`INSERT INTO energy_data (timestamp_unix, timestamp_rfc3339, e_in, e_out, power) VALUES (?, ?, ?, 0, 0)`,
+			ts.Unix(), ts.Format(time.RFC3339), eIn); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	db.RunAggregations(dbh, cfg)
+
+	pub := fakePublisher{}
+	PublishAggregates(pub, dbh, cfg)
+
+	for _, topic := range []string{"home/energy/today", "home/energy/month", "custom/year", "home/tariff"} {
+		msg, ok := pub[topic]
+		if !ok {
+			t.Fatalf("nothing published to %s (got %v)", topic, pub)
+		}
+		if !msg.retained || msg.qos != 1 {
+			t.Fatalf("%s: expected retained qos 1, got %+v", topic, msg)
+		}
+	}
+
+	var today struct {
+		Consumption float64 `json:"consumption_kwh"`
+		Cost        float64 `json:"cost"`
+	}
+	if err := json.Unmarshal(pub["home/energy/today"].payload, &today); err != nil {
+		t.Fatalf("decode today: %v", err)
+	}
+	if today.Consumption != 4 || today.Cost != 2 {
+		t.Fatalf("unexpected today payload: %s", pub["home/energy/today"].payload)
+	}
+}
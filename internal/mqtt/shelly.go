@@ -0,0 +1,218 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -------------------------------------------------------------------
+// Shelly: Gen1 (ein Topic pro Wert) und Gen2 (RPC/Status als JSON)
+// -------------------------------------------------------------------
+
+// shellyReading sind die Werte eines Kanals (Relais bzw. Phase 0..2).
+// Nicht gemeldete Werte bleiben nil.
+type shellyReading struct {
+	Channel  int
+	Power    *float64 // W
+	Voltage  *float64 // V
+	Current  *float64 // A
+	Energy   *float64 // kWh Bezug
+	Returned *float64 // kWh Einspeisung
+}
+
+// shellyMessage ist eine dekodierte Nachricht; Time ist leer, wenn das
+// Gerät keinen Zeitstempel mitschickt
+type shellyMessage struct {
+	Device   string
+	Time     time.Time
+	Readings []shellyReading
+}
+
+var errShellyTopic = errors.New("unbekanntes Shelly-Topic")
+
+// decodeShelly erkennt Gen1- und Gen2-Topics. Nachrichten ohne Messwerte
+// (z.B. Relais an/aus) liefern eine leere Reading-Liste.
+func decodeShelly(topic, payload string) (shellyMessage, error) {
+	seg := strings.Split(topic, "/")
+	for i, s := range seg {
+		switch {
+		case s == "events" && i == len(seg)-2 && seg[i+1] == "rpc" && i > 0:
+			return decodeShellyRPC(seg[i-1], payload)
+		case s == "status" && i == len(seg)-2 && i > 0:
+			readings, err := shellyComponent(seg[i+1], json.RawMessage(payload))
+			return shellyMessage{Device: seg[i-1], Readings: readings}, err
+		case (s == "relay" || s == "emeter") && i > 0 && i == len(seg)-3:
+			return decodeShellyGen1(seg[i-1], s, seg[i+1], seg[i+2], payload)
+		}
+	}
+	return shellyMessage{}, errShellyTopic
+}
+
+// decodeShellyGen1 liest <id>/relay/<n>/<wert> und <id>/emeter/<n>/<wert>
+func decodeShellyGen1(device, kind, channel, metric, payload string) (shellyMessage, error) {
+	msg := shellyMessage{Device: device}
+	ch, err := strconv.Atoi(channel)
+	if err != nil {
+		return msg, errShellyTopic
+	}
+
+	var scale float64
+	r := shellyReading{Channel: ch}
+	var field **float64
+	switch kind + "/" + metric {
+	case "relay/power", "emeter/power":
+		field, scale = &r.Power, 1
+	case "emeter/voltage":
+		field, scale = &r.Voltage, 1
+	case "emeter/current":
+		field, scale = &r.Current, 1
+	case "relay/energy":
+		// Gen1-Relais zählen in Wattminuten
+		field, scale = &r.Energy, 1.0/60000
+	case "emeter/total":
+		field, scale = &r.Energy, 1.0/1000
+	case "emeter/total_returned":
+		field, scale = &r.Returned, 1.0/1000
+	default:
+		return msg, nil
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(payload), 64)
+	if err != nil {
+		return msg, fmt.Errorf("Wert %q: %w", payload, err)
+	}
+	v *= scale
+	*field = &v
+	msg.Readings = []shellyReading{r}
+	return msg, nil
+}
+
+// decodeShellyRPC liest NotifyStatus/NotifyFullStatus aus <id>/events/rpc
+func decodeShellyRPC(prefix, payload string) (shellyMessage, error) {
+	var rpc struct {
+		Src    string                     `json:"src"`
+		Method string                     `json:"method"`
+		Params map[string]json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(payload), &rpc); err != nil {
+		return shellyMessage{}, err
+	}
+
+	msg := shellyMessage{Device: rpc.Src}
+	if msg.Device == "" {
+		msg.Device = prefix
+	}
+	if rpc.Method != "NotifyStatus" && rpc.Method != "NotifyFullStatus" {
+		return msg, nil
+	}
+	if raw, ok := rpc.Params["ts"]; ok {
+		var ts float64
+		if json.Unmarshal(raw, &ts) == nil && ts > 0 {
+			sec, frac := math.Modf(ts)
+			msg.Time = time.Unix(int64(sec), int64(frac*1e9))
+		}
+	}
+	components := slices.Sorted(maps.Keys(rpc.Params))
+	for _, component := range components {
+		readings, err := shellyComponent(component, rpc.Params[component])
+		if err != nil {
+			return msg, err
+		}
+		msg.Readings = append(msg.Readings, readings...)
+	}
+	return msg, nil
+}
+
+// shellyComponent dekodiert den Status einer Gen2-Komponente
+// (switch:N, em:0, emdata:0, em1:N, em1data:N); andere werden ignoriert
+func shellyComponent(component string, raw json.RawMessage) ([]shellyReading, error) {
+	kind, idx, _ := strings.Cut(component, ":")
+	ch, _ := strconv.Atoi(idx)
+	kwh := func(wh *float64) *float64 {
+		if wh == nil {
+			return nil
+		}
+		v := *wh / 1000
+		return &v
+	}
+
+	switch kind {
+	case "switch", "em1":
+		var s struct {
+			APower   *float64 `json:"apower"`
+			ActPower *float64 `json:"act_power"`
+			Voltage  *float64 `json:"voltage"`
+			Current  *float64 `json:"current"`
+			AEnergy  *struct {
+				Total *float64 `json:"total"`
+			} `json:"aenergy"`
+			RetAEnergy *struct {
+				Total *float64 `json:"total"`
+			} `json:"ret_aenergy"`
+		}
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		r := shellyReading{Channel: ch, Power: s.APower, Voltage: s.Voltage, Current: s.Current}
+		if r.Power == nil {
+			r.Power = s.ActPower
+		}
+		if s.AEnergy != nil {
+			r.Energy = kwh(s.AEnergy.Total)
+		}
+		if s.RetAEnergy != nil {
+			r.Returned = kwh(s.RetAEnergy.Total)
+		}
+		return nonEmpty(r), nil
+
+	case "em1data":
+		var s struct {
+			Total    *float64 `json:"total_act_energy"`
+			Returned *float64 `json:"total_act_ret_energy"`
+		}
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return nonEmpty(shellyReading{Channel: ch, Energy: kwh(s.Total), Returned: kwh(s.Returned)}), nil
+
+	case "em", "emdata":
+		// Drehstrom (3EM/Pro3EM): Felder mit Präfix a_, b_, c_ pro Phase
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		get := func(name string) *float64 {
+			var v *float64
+			if f, ok := fields[name]; ok {
+				_ = json.Unmarshal(f, &v)
+			}
+			return v
+		}
+		var out []shellyReading
+		for phase, p := range []string{"a_", "b_", "c_"} {
+			r := shellyReading{Channel: phase}
+			if kind == "em" {
+				r.Power, r.Voltage, r.Current = get(p+"act_power"), get(p+"voltage"), get(p+"current")
+			} else {
+				r.Energy, r.Returned = kwh(get(p+"total_act_energy")), kwh(get(p+"total_act_ret_energy"))
+			}
+			out = append(out, nonEmpty(r)...)
+		}
+		return out, nil
+	}
+	return nil, nil
+}
+
+func nonEmpty(r shellyReading) []shellyReading {
+	if r.Power == nil && r.Voltage == nil && r.Current == nil && r.Energy == nil && r.Returned == nil {
+		return nil
+	}
+	return []shellyReading{r}
+}
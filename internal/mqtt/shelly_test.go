@@ -0,0 +1,131 @@
+package mqtt
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+func TestDecodeShelly(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		device  string
+		want    []shellyReading
+	}{
+		{"gen1 relay power", "shellies/shellyplug-s-7A1B2C/relay/0/power", "42.5", "shellyplug-s-7A1B2C",
+			[]shellyReading{{Channel: 0, Power: f(42.5)}}},
+		{"gen1 relay energy in Wattminuten", "shellies/plug/relay/1/energy", "60000", "plug",
+			[]shellyReading{{Channel: 1, Energy: f(1)}}},
+		{"gen1 3em phase", "shellies/shellyem3-1/emeter/2/total_returned", "1500", "shellyem3-1",
+			[]shellyReading{{Channel: 2, Returned: f(1.5)}}},
+		{"gen1 relay state", "shellies/plug/relay/0/overpower_value", "0", "plug", nil},
+		{"gen2 switch status", "shellyplus1pm-a1/status/switch:0",
+			`{"id":0,"apower":12.3,"voltage":231.1,"current":0.1,"aenergy":{"total":2500.0}}`, "shellyplus1pm-a1",
+			[]shellyReading{{Channel: 0, Power: f(12.3), Voltage: f(231.1), Current: f(0.1), Energy: f(2.5)}}},
+		{"gen2 pro3em", "home/pro3em/status/em:0",
+			`{"id":0,"a_act_power":100,"a_voltage":230,"b_act_power":200,"c_act_power":-50,"total_act_power":250}`, "pro3em",
+			[]shellyReading{{Channel: 0, Power: f(100), Voltage: f(230)}, {Channel: 1, Power: f(200)}, {Channel: 2, Power: f(-50)}}},
+		{"gen2 rpc notify", "shellyplus1pm-a1/events/rpc",
+			`{"src":"shellyplus1pm-a1","method":"NotifyStatus","params":{"ts":1700000000.5,"switch:1":{"id":1,"apower":7}}}`, "shellyplus1pm-a1",
+			[]shellyReading{{Channel: 1, Power: f(7)}}},
+		{"gen2 rpc event", "shellyplus1pm-a1/events/rpc",
+			`{"src":"shellyplus1pm-a1","method":"NotifyEvent","params":{"events":[]}}`, "shellyplus1pm-a1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := decodeShelly(tt.topic, tt.payload)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if msg.Device != tt.device {
+				t.Fatalf("device = %q, want %q", msg.Device, tt.device)
+			}
+			if len(msg.Readings) != len(tt.want) {
+				t.Fatalf("readings = %+v, want %+v", msg.Readings, tt.want)
+			}
+			for i, r := range msg.Readings {
+				w := tt.want[i]
+				if r.Channel != w.Channel || !sameValue(r.Power, w.Power) || !sameValue(r.Voltage, w.Voltage) ||
+					!sameValue(r.Current, w.Current) || !sameValue(r.Energy, w.Energy) || !sameValue(r.Returned, w.Returned) {
+					t.Fatalf("reading %d = %+v, want %+v", i, r, w)
+				}
+			}
+		})
+	}
+
+	if _, err := decodeShelly("shellies/plug/online", "true"); !errors.Is(err, errShellyTopic) {
+		t.Fatalf("online topic: err = %v", err)
+	}
+	if _, err := decodeShelly("shellies/plug/relay/0/power", "n/a"); err == nil {
+		t.Fatal("expected error for non-numeric value")
+	}
+	msg, _ := decodeShelly("x/events/rpc", `{"src":"x","method":"NotifyStatus","params":{"ts":1700000000.5}}`)
+	if msg.Time.Unix() != 1700000000 {
+		t.Fatalf("rpc ts = %v", msg.Time)
+	}
+}
+
+func sameValue(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return math.Abs(*a-*b) < 1e-9
+}
+
+func TestHandleShellyPersistsPhases(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	handleShelly("pro3em/status/em:0", `{"a_act_power":100,"b_act_power":200,"c_act_power":300}`, db, config.Config{})
+	handleShelly("shellies/plug/relay/0/power", "5", db, config.Config{})
+	handleShelly("shellies/plug/announce", `{"id":"plug"}`, db, config.Config{})
+
+	var rows int
+	var sum float64
+	if err := db.QueryRow(`SELECT COUNT(*), SUM(power) FROM shelly_data`).Scan(&rows, &sum); err != nil {
+		t.Fatalf("select shelly_data: %v", err)
+	}
+	if rows != 4 || sum != 605 {
+		t.Fatalf("rows=%d sum=%v, want 4 rows with 605 W", rows, sum)
+	}
+
+	var devices int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM devices WHERE source = 'shelly'`).Scan(&devices); err != nil {
+		t.Fatalf("select devices: %v", err)
+	}
+	if devices != 2 {
+		t.Fatalf("devices = %d, want 2", devices)
+	}
+}
+
+func TestHandleShellyStoresLocalTime(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata: %v", err)
+	}
+	cfg := config.Config{Time: config.TimeConfig{Timezone: "Europe/Berlin"}}
+	handleShelly("shellies/plug/relay/0/power", "5", db, cfg)
+
+	var raw string
+	if err := db.QueryRow(`SELECT timestamp_rfc3339 FROM shelly_data`).Scan(&raw); err != nil {
+		t.Fatalf("select shelly_data: %v", err)
+	}
+	ts, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		t.Fatalf("parse %q: %v", raw, err)
+	}
+	_, got := ts.Zone()
+	_, want := ts.In(berlin).Zone()
+	if got != want {
+		t.Fatalf("timestamp_rfc3339 = %s, want offset of Europe/Berlin", raw)
+	}
+}
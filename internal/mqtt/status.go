@@ -0,0 +1,149 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+	"github.com/khorsmann/mqttlogger/internal/health"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+	"github.com/khorsmann/mqttlogger/internal/version"
+)
+
+// -------------------------------------------------------------------
+// Online-Status (Last Will) und Heartbeat
+// -------------------------------------------------------------------
+
+const defaultHeartbeatInterval = time.Minute
+
+var startTime = time.Now()
+
+// StatusTopics beschreibt Status-Topic, Payloads und Heartbeat-Topic
+type StatusTopics struct {
+	Topic     string
+	Online    string
+	Offline   string
+	Heartbeat string
+	Interval  time.Duration
+}
+
+// Status liefert die Status-Konfiguration mit Standardwerten
+func Status(cfg config.Config) StatusTopics {
+	prefix := PublishTopics(cfg.Publish).Prefix
+	s := StatusTopics{
+		Topic:     cfg.Status.Topic,
+		Online:    cfg.Status.PayloadOnline,
+		Offline:   cfg.Status.PayloadOffline,
+		Heartbeat: cfg.Status.HeartbeatTopic,
+		Interval:  cfg.Status.HeartbeatInterval,
+	}
+	if s.Topic == "" {
+		s.Topic = prefix + "/status"
+	}
+	if s.Online == "" {
+		s.Online = "online"
+	}
+	if s.Offline == "" {
+		s.Offline = "offline"
+	}
+	if s.Heartbeat == "" {
+		s.Heartbeat = prefix + "/heartbeat"
+	}
+	if s.Interval <= 0 {
+		s.Interval = defaultHeartbeatInterval
+	}
+	return s
+}
+
+// statusEnabled: Veröffentlichung und Home Assistant brauchen den Status ebenfalls
+func statusEnabled(cfg config.Config) bool {
+	return cfg.Status.Enabled || publishEnabled(cfg)
+}
+
+// SourceStatus ist der Empfangsstatus eines konfigurierten Topics
+type SourceStatus struct {
+	Source         string   `json:"source"`
+	Topic          string   `json:"topic"`
+	LastMessageAge *float64 `json:"last_message_age_s"`
+}
+
+// Heartbeat ist die periodische Statusmeldung des Loggers
+type Heartbeat struct {
+	Version     string           `json:"version"`
+	Time        time.Time        `json:"time"`
+	UptimeS     float64          `json:"uptime_s"`
+	DBSizeBytes int64            `json:"db_size_bytes"`
+	RowsWritten map[string]int64 `json:"rows_written"`
+	Sources     []SourceStatus   `json:"sources"`
+}
+
+// sourceTopic liefert die Topics einer Quelle über alle Broker
+func sourceTopic(cfg config.Config, source string) string {
+	var topics []string
+	for _, b := range cfg.BrokerList() {
+		var t string
+		switch source {
+		case "wattwaechter":
+			t = b.Topics.Wattwaechter
+		case "tasmota":
+			t = strings.Join(b.Topics.Tasmota, ", ")
+		case "shelly":
+			t = strings.Join(b.Topics.Shelly, ", ")
+		case "zigbee":
+			t = b.Topics.Zigbee2MQTT
+		case "sml":
+			t = strings.Join(b.Topics.SML, ", ")
+		case "solar":
+			t = b.Topics.Solar
+		}
+		if t != "" && !slices.Contains(topics, t) {
+			topics = append(topics, t)
+		}
+	}
+	return strings.Join(topics, ", ")
+}
+
+func buildHeartbeat(cfg config.Config, tr *health.Tracker, now time.Time) Heartbeat {
+	hb := Heartbeat{
+		Version:     version.String(),
+		Time:        now,
+		UptimeS:     now.Sub(startTime).Truncate(time.Second).Seconds(),
+		DBSizeBytes: db.FileSize(cfg.Database.Path),
+		RowsWritten: map[string]int64{},
+		Sources:     []SourceStatus{},
+	}
+	for _, src := range health.Sources(cfg) {
+		hb.RowsWritten[src] = int64(metrics.MessagesStored.Value(src))
+		st := SourceStatus{Source: src, Topic: sourceTopic(cfg, src)}
+		if ts, ok := tr.LastSeen(src); ok {
+			age := now.Sub(ts).Truncate(time.Second).Seconds()
+			st.LastMessageAge = &age
+		}
+		hb.Sources = append(hb.Sources, st)
+	}
+	return hb
+}
+
+// startHeartbeat veröffentlicht den Heartbeat im konfigurierten Intervall
+func startHeartbeat(client publisher, cfg config.Config) {
+	st := Status(cfg)
+	go func() {
+		ticker := time.NewTicker(st.Interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			payload, err := json.Marshal(buildHeartbeat(cfg, health.Default, time.Now()))
+			if err != nil {
+				log.Printf("[Status] JSON-Fehler: %v", err)
+				continue
+			}
+			if err := client.Publish(st.Heartbeat, cfg.Publish.Qos, false, payload); err != nil {
+				log.Printf("[Status] Heartbeat nicht gesendet: %v", err)
+			}
+		}
+	}()
+}
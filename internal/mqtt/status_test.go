@@ -0,0 +1,53 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/health"
+)
+
+func TestStatusDefaults(t *testing.T) {
+	st := Status(config.Config{Publish: config.PublishConfig{TopicPrefix: "home"}})
+	if st.Topic != "home/status" || st.Heartbeat != "home/heartbeat" {
+		t.Fatalf("unexpected topics: %+v", st)
+	}
+	if st.Online != "online" || st.Offline != "offline" || st.Interval != time.Minute {
+		t.Fatalf("unexpected defaults: %+v", st)
+	}
+
+	st = Status(config.Config{Status: config.StatusConfig{Topic: "logger/lwt", PayloadOffline: "dead"}})
+	if st.Topic != "logger/lwt" || st.Offline != "dead" || st.Online != "online" {
+		t.Fatalf("overrides ignored: %+v", st)
+	}
+}
+
+func TestBuildHeartbeatReportsSourceAge(t *testing.T) {
+	cfg := config.Config{
+		Database: config.DatabaseConfig{Path: t.TempDir() + "/missing.db"},
+		Topics: config.TopicsConfig{Wattwaechter: "tele/ww/SENSOR", Tasmota: config.TopicList{"tele/+/SENSOR"},
+			Zigbee2MQTT: "zigbee2mqtt/#"},
+	}
+	now := time.Date(2025, 11, 24, 12, 0, 0, 0, time.UTC)
+	tr := health.NewTracker()
+	tr.SeenAt("wattwaechter", now.Add(-90*time.Second))
+
+	hb := buildHeartbeat(cfg, tr, now)
+	if hb.Version == "" || hb.DBSizeBytes != 0 {
+		t.Fatalf("unexpected heartbeat: %+v", hb)
+	}
+	if len(hb.Sources) != 3 {
+		t.Fatalf("expected 3 sources, got %+v", hb.Sources)
+	}
+	if _, ok := hb.RowsWritten["zigbee"]; !ok || len(hb.RowsWritten) != 3 {
+		t.Fatalf("rows written per source: %+v", hb.RowsWritten)
+	}
+	ww, tas := hb.Sources[0], hb.Sources[1]
+	if ww.Topic != "tele/ww/SENSOR" || ww.LastMessageAge == nil || *ww.LastMessageAge != 90 {
+		t.Fatalf("unexpected wattwaechter status: %+v", ww)
+	}
+	if tas.LastMessageAge != nil {
+		t.Fatalf("tasmota never seen, got age %v", *tas.LastMessageAge)
+	}
+}
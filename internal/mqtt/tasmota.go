@@ -0,0 +1,70 @@
+package mqtt
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+// -------------------------------------------------------------------
+// Tasmota: Geräte-ID aus dem Topic, Allow-/Deny-Liste, Umbenennung
+// -------------------------------------------------------------------
+
+const defaultFullTopic = "%prefix%/%topic%/"
+
+// tasmotaDeviceID liest die Geräte-ID anhand des FullTopic-Templates aus dem
+// Topic. Hinter dem FullTopic folgt genau ein Segment (z.B. SENSOR).
+// %topic%, %hostname% und %id% gelten als Geräte-ID, %prefix% als beliebig.
+func tasmotaDeviceID(fullTopic, topic string) (string, bool) {
+	if fullTopic == "" {
+		fullTopic = defaultFullTopic
+	}
+	tpl := strings.Split(strings.Trim(fullTopic, "/"), "/")
+	seg := strings.Split(topic, "/")
+	if len(seg) != len(tpl)+1 {
+		return "", false
+	}
+
+	device := ""
+	for i, t := range tpl {
+		switch t {
+		case "%topic%", "%hostname%", "%id%":
+			// %topic% hat Vorrang vor Hostname und MAC-basierter ID
+			if device == "" || t == "%topic%" {
+				device = seg[i]
+			}
+		case "%prefix%":
+		default:
+			if t != seg[i] {
+				return "", false
+			}
+		}
+	}
+	return device, device != ""
+}
+
+// tasmotaDevice liefert die zu speichernde Geräte-ID. ok=false bei nicht
+// passendem Topic, allowed=false bei per Allow-/Deny-Liste ausgeschlossenen
+// Geräten. Die Listen gelten für Topic-Namen und umbenannte IDs.
+func tasmotaDevice(cfg config.TasmotaConfig, topic string) (device string, ok, allowed bool) {
+	raw, ok := tasmotaDeviceID(cfg.FullTopic, topic)
+	if !ok {
+		return "", false, false
+	}
+	device = raw
+	if name := cfg.Names[raw]; name != "" {
+		device = name
+	}
+
+	listed := func(list []string) bool {
+		return slices.Contains(list, raw) || slices.Contains(list, device)
+	}
+	if listed(cfg.Deny) {
+		return device, true, false
+	}
+	if len(cfg.Allow) > 0 && !listed(cfg.Allow) {
+		return device, true, false
+	}
+	return device, true, true
+}
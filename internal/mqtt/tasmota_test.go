@@ -0,0 +1,115 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+func TestTasmotaDeviceID(t *testing.T) {
+	cases := []struct {
+		fullTopic, topic, want string
+		ok                     bool
+	}{
+		{"", "tele/plug1/SENSOR", "plug1", true},
+		{"%prefix%/%topic%/", "tele/plug1/SENSOR", "plug1", true},
+		{"haus/keller/%topic%/%prefix%/", "haus/keller/plug2/tele/SENSOR", "plug2", true},
+		{"haus/keller/%topic%/%prefix%/", "haus/garten/plug2/tele/SENSOR", "", false},
+		{"%prefix%/%hostname%/%topic%/", "tele/tasmota-1A2B/plug3/SENSOR", "plug3", true},
+		{"%prefix%/%id%/", "tele/1A2B3C/SENSOR", "1A2B3C", true},
+		{"", "SENSOR", "", false},
+		{"", "tele/SENSOR", "", false},
+		{"", "tele/a/b/SENSOR", "", false},
+	}
+	for _, c := range cases {
+		got, ok := tasmotaDeviceID(c.fullTopic, c.topic)
+		if got != c.want || ok != c.ok {
+			t.Errorf("tasmotaDeviceID(%q, %q) = %q, %v; want %q, %v", c.fullTopic, c.topic, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestTasmotaAllowDenyAndNames(t *testing.T) {
+	cfg := config.TasmotaConfig{
+		Allow: []string{"waschmaschine", "plug2"},
+		Deny:  []string{"plug2"},
+		Names: map[string]string{"tasmota_8A3F1C": "waschmaschine"},
+	}
+
+	if dev, ok, allowed := tasmotaDevice(cfg, "tele/tasmota_8A3F1C/SENSOR"); !ok || !allowed || dev != "waschmaschine" {
+		t.Fatalf("renamed device: %q %v %v", dev, ok, allowed)
+	}
+	if _, ok, allowed := tasmotaDevice(cfg, "tele/plug2/SENSOR"); !ok || allowed {
+		t.Fatal("deny must win over allow")
+	}
+	if _, ok, allowed := tasmotaDevice(cfg, "tele/plug3/SENSOR"); !ok || allowed {
+		t.Fatal("device not in allow list must be skipped")
+	}
+}
+
+func TestHandleTasmotaIgnoresShortTopic(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	// früher: index out of range
+	handleTasmota("SENSOR", `{"ENERGY":{"Power":1}}`, db, config.Config{})
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tasmota_data`).Scan(&count); err != nil || count != 0 {
+		t.Fatalf("expected no rows, got %d (%v)", count, err)
+	}
+}
+
+func TestTasmotaRegistersDeviceAndMetadata(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	if got := tasmotaMetaTopics([]string{"tele/+/SENSOR", "other/topic"}); len(got) != 4 || got[0] != "tele/+/STATE" {
+		t.Fatalf("unexpected meta topics: %v", got)
+	}
+
+	handleTasmota("tele/plug1/SENSOR", `{"Time":"2025-11-24T19:00:00Z","ENERGY":{"Power":42}}`, db, config.Config{})
+	handleTasmotaMeta("tele/plug1/STATE", `{"POWER":"ON"}`, db, config.Config{})
+	handleTasmotaMeta("tele/plug1/INFO1", `kein json`, db, config.Config{})
+
+	var lastSeen int64
+	var state string
+	if err := db.QueryRow(`SELECT last_seen, state FROM devices WHERE source = 'tasmota' AND device_id = 'plug1'`).Scan(&lastSeen, &state); err != nil {
+		t.Fatalf("select devices: %v", err)
+	}
+	if lastSeen != 1764010800 || state != `{"POWER":"ON"}` {
+		t.Fatalf("unexpected device row: last_seen=%d state=%s", lastSeen, state)
+	}
+}
+
+func TestTouchDeviceThrottlesUpserts(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	seen := func() (first, last int64) {
+		t.Helper()
+		if err := db.QueryRow(`SELECT first_seen, last_seen FROM devices WHERE source = 'tasmota' AND device_id = 'plug9'`).Scan(&first, &last); err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		return first, last
+	}
+
+	ts := time.Unix(1_700_000_000, 0)
+	touchDevice(db, "tasmota", "plug9", ts)
+	touchDevice(db, "tasmota", "plug9", ts.Add(10*time.Second))
+	if _, last := seen(); last != ts.Unix() {
+		t.Fatalf("last_seen written within a minute: %d", last)
+	}
+
+	touchDevice(db, "tasmota", "plug9", ts.Add(2*time.Minute))
+	if _, last := seen(); last != ts.Add(2*time.Minute).Unix() {
+		t.Fatalf("last_seen not updated after a minute: %d", last)
+	}
+
+	// ältere Zeitstempel verschieben first_seen sofort
+	touchDevice(db, "tasmota", "plug9", ts.Add(-time.Hour))
+	if first, _ := seen(); first != ts.Add(-time.Hour).Unix() {
+		t.Fatalf("first_seen not moved back: %d", first)
+	}
+}
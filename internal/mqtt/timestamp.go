@@ -0,0 +1,122 @@
+package mqtt
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+)
+
+// -------------------------------------------------------------------
+// Zeitstempel: Geräte-Zeit parsen, Richtlinie anwenden, Uhrabweichung
+// -------------------------------------------------------------------
+
+// defaultTimeLayouts werden nach [time] input_format(s) probiert
+var defaultTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// parseDeviceTime liest eine Geräte-Zeit. Layouts ohne Zone gelten in
+// [time] timezone, "unix" und "unix_ms" erwarten Zahlen.
+func parseDeviceTime(tc config.TimeConfig, raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, fmt.Errorf("kein Zeitstempel")
+	}
+	layouts := tc.InputFormats
+	if tc.InputFormat != "" {
+		layouts = append([]string{tc.InputFormat}, layouts...)
+	}
+	loc := tc.Location()
+	for _, layout := range append(layouts, defaultTimeLayouts...) {
+		switch layout {
+		case "unix", "unix_ms":
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil || v <= 0 {
+				continue
+			}
+			if layout == "unix_ms" {
+				return time.UnixMilli(int64(v)), nil
+			}
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(frac*1e9)), nil
+		default:
+			if t, err := time.ParseInLocation(layout, raw, loc); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("unbekanntes Zeitformat %q", raw)
+}
+
+// deviceTime parst raw und zählt Formatfehler; bei Fehlern ist das
+// Ergebnis leer und resolveTime nimmt die Empfangszeit
+func deviceTime(cfg config.Config, source, raw string) time.Time {
+	t, err := parseDeviceTime(cfg.Time, raw)
+	if err != nil {
+		log.Printf("[%s] Zeitformatfehler: %v", source, err)
+		metrics.Errors.Inc(source, "time")
+	}
+	return t
+}
+
+// resolveTime wählt nach der Richtlinie der Quelle zwischen Geräte-Zeit
+// und Empfangszeit. Eine leere Geräte-Zeit ergibt immer now.
+func resolveTime(cfg config.Config, source, device string, dev, now time.Time) time.Time {
+	if dev.IsZero() {
+		return now
+	}
+	p := cfg.Time.PolicyFor(source)
+	skew := checkSkew(p, source, device, dev, now)
+
+	switch p.Policy {
+	case config.TimeReceive:
+		return now
+	case config.TimeDeviceMaxSkew:
+		if skew.Abs() > p.MaxSkew {
+			return now
+		}
+	}
+	return dev
+}
+
+// clockSkewed merkt sich Geräte mit abweichender Uhr, damit nur beim
+// Wechsel geloggt wird
+var clockSkewed = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: map[string]bool{}}
+
+// checkSkew vergleicht Geräte- und Empfangszeit, zählt Abweichungen über
+// warn_skew und liefert die Abweichung (Gerät minus Empfang)
+func checkSkew(p config.TimePolicy, source, device string, dev, now time.Time) time.Duration {
+	skew := dev.Sub(now)
+	metrics.ClockSkewSeconds.Set(skew.Seconds(), source, device)
+
+	key := source + "/" + device
+	skewed := skew.Abs() > p.WarnSkew
+	if skewed {
+		metrics.ClockSkew.Inc(source)
+	}
+
+	clockSkewed.Lock()
+	was := clockSkewed.m[key]
+	clockSkewed.m[key] = skewed
+	clockSkewed.Unlock()
+
+	switch {
+	case skewed && !was:
+		log.Printf("[%s] Uhr von %q weicht um %s ab (Grenze %s)", source, device, skew.Round(time.Second), p.WarnSkew)
+	case !skewed && was:
+		log.Printf("[%s] Uhr von %q wieder synchron (%s)", source, device, skew.Round(time.Second))
+	}
+	return skew
+}
@@ -0,0 +1,77 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+)
+
+func TestParseDeviceTime(t *testing.T) {
+	tc := config.TimeConfig{Timezone: "Europe/Berlin", InputFormats: []string{"02.01.2006 15:04:05", "unix_ms"}}
+	want := time.Date(2025, 11, 24, 19, 0, 0, 0, time.UTC)
+	for _, raw := range []string{
+		"2025-11-24T19:00:00Z",
+		"2025-11-24T20:00:00+01:00",
+		"2025-11-24T20:00:00",
+		"2025-11-24 20:00:00",
+		"24.11.2025 20:00:00",
+		"1764010800000",
+	} {
+		got, err := parseDeviceTime(tc, raw)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("%q: %v %v, want %v", raw, got, err, want)
+		}
+	}
+	if _, err := parseDeviceTime(tc, "gestern"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestResolveTimePolicies(t *testing.T) {
+	now := time.Date(2025, 11, 24, 19, 0, 0, 0, time.UTC)
+	slow := now.Add(-10 * time.Minute)
+	near := now.Add(-30 * time.Second)
+
+	cfg := config.Config{Time: config.TimeConfig{
+		TimePolicy: config.TimePolicy{MaxSkew: 5 * time.Minute, WarnSkew: time.Minute},
+		Sources: map[string]config.TimePolicy{
+			"solar":  {Policy: config.TimeReceive},
+			"shelly": {Policy: config.TimeDeviceMaxSkew},
+		},
+	}}
+	tests := []struct {
+		source string
+		dev    time.Time
+		want   time.Time
+	}{
+		{"tasmota", slow, slow},
+		{"tasmota", time.Time{}, now},
+		{"solar", near, now},
+		{"shelly", near, near},
+		{"shelly", slow, now},
+	}
+	for _, tt := range tests {
+		if got := resolveTime(cfg, tt.source, "d1", tt.dev, now); !got.Equal(tt.want) {
+			t.Errorf("%s %v: got %v, want %v", tt.source, tt.dev, got, tt.want)
+		}
+	}
+}
+
+func TestClockSkewCounted(t *testing.T) {
+	cfg := config.Config{Time: config.TimeConfig{TimePolicy: config.TimePolicy{WarnSkew: time.Minute}}}
+	now := time.Now()
+	before := metrics.ClockSkew.Value("skewtest")
+
+	resolveTime(cfg, "skewtest", "uhr", now.Add(30*time.Second), now)
+	resolveTime(cfg, "skewtest", "uhr", now.Add(-3*time.Minute), now)
+	resolveTime(cfg, "skewtest", "uhr", now.Add(-3*time.Minute), now)
+
+	if got := metrics.ClockSkew.Value("skewtest") - before; got != 2 {
+		t.Fatalf("skew warnings = %v, want 2", got)
+	}
+	if got := metrics.ClockSkewSeconds.Value("skewtest", "uhr"); got != -180 {
+		t.Fatalf("skew seconds = %v", got)
+	}
+}
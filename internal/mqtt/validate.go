@@ -0,0 +1,154 @@
+package mqtt
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+)
+
+// -------------------------------------------------------------------
+// Plausibilitätsprüfung: Wertebereich, Änderungsrate, monotone Zähler
+// -------------------------------------------------------------------
+
+// violation ist eine verletzte Regel; kind ist range, rate oder monotonic
+type violation struct {
+	metric string
+	value  float64
+	kind   string
+	reason string
+}
+
+// checkReading prüft eine Zeile für table gegen [validation]
+func checkReading(ex db.Execer, cfg config.Config, source, table string, columns []string, args []any) (*violation, error) {
+	if len(cfg.Validation.Rules) == 0 {
+		return nil, nil
+	}
+	row := map[string]any{}
+	for i, c := range columns {
+		row[c] = args[i]
+	}
+	identityCols := db.ReadingIdentity(table)
+	identity := make([]any, len(identityCols))
+	for i, c := range identityCols {
+		identity[i] = row[c]
+	}
+	device := readingDevice(row, identityCols)
+	ts, _ := toFloat(row["timestamp_unix"])
+
+	for _, c := range columns {
+		if c == "timestamp_unix" || c == "timestamp_rfc3339" || c == "metric" || slices.Contains(identityCols, c) {
+			continue
+		}
+		v, ok := toFloat(row[c])
+		if !ok {
+			continue
+		}
+		metric := c
+		if m, ok := row["metric"].(string); ok && c == "value" {
+			// solar_data: eine Zeile pro Metrik
+			metric = m
+		}
+		rule, ok := cfg.Validation.RuleFor(source, device, metric)
+		if !ok {
+			continue
+		}
+
+		if rule.Min != nil && v < *rule.Min {
+			return &violation{metric, v, "range", fmt.Sprintf("%s = %g unter Minimum %g", metric, v, *rule.Min)}, nil
+		}
+		if rule.Max != nil && v > *rule.Max {
+			return &violation{metric, v, "range", fmt.Sprintf("%s = %g über Maximum %g", metric, v, *rule.Max)}, nil
+		}
+		if rule.MaxRate <= 0 && !rule.Monotonic {
+			continue
+		}
+		recent, err := db.RecentValues(ex, table, c, identity, int64(ts), referenceWindow)
+		if err != nil {
+			return nil, err
+		}
+		if len(recent) == 0 {
+			continue
+		}
+		ref := reference(recent)
+		prev, prevTS := ref.Value, ref.TS
+		if rule.Monotonic && v < prev {
+			return &violation{metric, v, "monotonic", fmt.Sprintf("%s fällt von %g auf %g", metric, prev, v)}, nil
+		}
+		hours := (ts - float64(prevTS)) / 3600
+		if rate := math.Abs(v-prev) / hours; rule.MaxRate > 0 && rate > rule.MaxRate {
+			return &violation{metric, v, "rate", fmt.Sprintf("%s ändert sich um %.1f/h (%g → %g, max %g/h)", metric, rate, prev, v, rule.MaxRate)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// referenceWindow: so viele gespeicherte Werte bilden den Vergleichswert
+const referenceWindow = 5
+
+// reference ist der (untere) Median der letzten gespeicherten Werte. Ein
+// einzelner gespeicherter Ausreißer sperrt so nicht alle folgenden Werte.
+func reference(recent []db.StoredValue) db.StoredValue {
+	sorted := slices.SortedFunc(slices.Values(recent), func(a, b db.StoredValue) int {
+		return cmp.Compare(a.Value, b.Value)
+	})
+	return sorted[(len(sorted)-1)/2]
+}
+
+// quarantine legt eine abgelehnte Zeile ab und zählt sie
+func quarantine(ex db.Execer, source, table string, columns []string, args []any, v *violation) error {
+	row := map[string]any{}
+	for i, c := range columns {
+		row[c] = args[i]
+	}
+	ts, _ := toFloat(row["timestamp_unix"])
+	value := v.value
+	err := db.AddQuarantine(ex, db.Quarantined{
+		Received: time.Now(),
+		Source:   source,
+		Device:   readingDevice(row, db.ReadingIdentity(table)),
+		Table:    table,
+		Time:     time.Unix(int64(ts), 0),
+		Metric:   v.metric,
+		Value:    &value,
+		Reason:   v.reason,
+		Row:      row,
+	})
+	if err != nil {
+		return err
+	}
+	metrics.Quarantined.Inc(source, v.kind)
+	log.Printf("[%s] Messwert in Quarantäne: %s", source, v.reason)
+	return nil
+}
+
+// readingDevice ist die erste Gerätespalte (device_id bzw. meter_id)
+func readingDevice(row map[string]any, identity []string) string {
+	if len(identity) == 0 || row[identity[0]] == nil {
+		return ""
+	}
+	return fmt.Sprint(row[identity[0]])
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case *float64:
+		if x == nil {
+			return 0, false
+		}
+		return *x, true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	}
+	return 0, false
+}
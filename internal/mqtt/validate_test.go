@@ -0,0 +1,110 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+)
+
+func TestWattwaechterOutliersGoToQuarantine(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	zero := 1.0
+	cfg := config.Config{
+		Time: config.TimeConfig{Timezone: "Europe/Berlin"},
+		Validation: config.ValidationConfig{Rules: map[string]config.ValidationRule{
+			"wattwaechter/e_in": {Min: &zero, MaxRate: 50, Monotonic: true},
+		}},
+	}
+	before := metrics.Quarantined.Value("wattwaechter", "rate")
+
+	for _, p := range []string{
+		`{"Time":"2025-11-24T20:00:00+01:00","E320":{"E_in":1000.0,"Power":400}}`,
+		`{"Time":"2025-11-24T20:15:00+01:00","E320":{"E_in":0,"Power":400}}`,
+		`{"Time":"2025-11-24T20:30:00+01:00","E320":{"E_in":4000.0,"Power":400}}`,
+		`{"Time":"2025-11-24T20:45:00+01:00","E320":{"E_in":999.5,"Power":400}}`,
+		`{"Time":"2025-11-24T21:00:00+01:00","E320":{"E_in":1000.4,"Power":400}}`,
+	} {
+		handleWattwaechter("tele/ww/SENSOR", p, db, cfg)
+	}
+
+	var stored int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM energy_data`).Scan(&stored); err != nil {
+		t.Fatalf("select energy_data: %v", err)
+	}
+	if stored != 2 {
+		t.Fatalf("stored = %d, want 2", stored)
+	}
+
+	rows, err := db.Query(`SELECT metric, reason FROM quarantine ORDER BY timestamp_unix`)
+	if err != nil {
+		t.Fatalf("select quarantine: %v", err)
+	}
+	defer rows.Close()
+	var reasons []string
+	for rows.Next() {
+		var metric, reason string
+		if err := rows.Scan(&metric, &reason); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		if metric != "e_in" {
+			t.Fatalf("metric = %q", metric)
+		}
+		reasons = append(reasons, reason)
+	}
+	if len(reasons) != 3 {
+		t.Fatalf("quarantine = %v", reasons)
+	}
+	if got := metrics.Quarantined.Value("wattwaechter", "rate") - before; got != 1 {
+		t.Fatalf("rate rejections = %v", got)
+	}
+}
+
+func TestSolarRulePerMetric(t *testing.T) {
+	limit := 800.0
+	cfg := config.Config{Validation: config.ValidationConfig{Rules: map[string]config.ValidationRule{
+		"solar/0/power": {Max: &limit},
+	}}}
+	cols := []string{"timestamp_unix", "device_id", "channel", "metric", "value"}
+
+	v, err := checkReading(nil, cfg, "solar", "solar_data", cols, []any{int64(1000), "hm800", -1, "0/power", 950.0})
+	if err != nil || v == nil || v.kind != "range" {
+		t.Fatalf("power: %+v %v", v, err)
+	}
+	v, err = checkReading(nil, cfg, "solar", "solar_data", cols, []any{int64(1000), "hm800", -1, "0/yieldtotal", 950.0})
+	if err != nil || v != nil {
+		t.Fatalf("yield: %+v %v", v, err)
+	}
+}
+
+func TestStoredOutlierDoesNotLockSeries(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	cfg := config.Config{Validation: config.ValidationConfig{Rules: map[string]config.ValidationRule{
+		"tasmota/energy_total": {MaxRate: 10, Monotonic: true},
+	}}}
+	// Ausreißer bei 1300, z.B. vor Einführung der Regel gespeichert
+	if _, err := db.Exec(`
+		INSERT INTO tasmota_data (device_id, timestamp_unix, energy_total) VALUES
+			('plug', 1000, 100.0), ('plug', 1100, 100.1), ('plug', 1200, 100.2), ('plug', 1300, 99999)
+	`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	cols := []string{"device_id", "timestamp_unix", "energy_total"}
+	for i, e := range []float64{100.3, 100.4, 100.5} {
+		stored, err := storeReading(db, cfg, "tasmota", "tasmota_data", cols, "plug", int64(1400+100*i), e)
+		if err != nil || !stored {
+			t.Fatalf("reading %g after outlier: stored = %v, %v", e, stored, err)
+		}
+	}
+
+	// gegen den Median fällt ein echter Rücksprung weiterhin auf
+	v, err := checkReading(db, cfg, "tasmota", "tasmota_data", cols, []any{"plug", int64(1800), 50.0})
+	if err != nil || v == nil || v.kind != "monotonic" {
+		t.Fatalf("drop: %+v %v", v, err)
+	}
+}
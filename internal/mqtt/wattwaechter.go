@@ -0,0 +1,67 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// -------------------------------------------------------------------
+// Wattwaechter: optionale Drehstrom- und Tarifgrößen im E320-Objekt
+// -------------------------------------------------------------------
+
+// wattwaechterColumns sind die optionalen Spalten in energy_data
+var wattwaechterColumns = []string{
+	"power_l1", "power_l2", "power_l3",
+	"voltage_l1", "voltage_l2", "voltage_l3",
+	"current_l1", "current_l2", "current_l3",
+	"frequency",
+	"e_in_t1", "e_in_t2", "e_out_t1", "e_out_t2",
+}
+
+// wattwaechterAliases bildet übliche Feldnamen der Lesekopf-Skripte auf die
+// Spalten ab. Schlüssel sind klein geschrieben und ohne "_", "-", ".".
+var wattwaechterAliases = map[string]string{
+	"powerl1": "power_l1", "power1": "power_l1", "pl1": "power_l1",
+	"powerl2": "power_l2", "power2": "power_l2", "pl2": "power_l2",
+	"powerl3": "power_l3", "power3": "power_l3", "pl3": "power_l3",
+
+	"voltagel1": "voltage_l1", "voltage1": "voltage_l1", "voltl1": "voltage_l1", "ul1": "voltage_l1",
+	"voltagel2": "voltage_l2", "voltage2": "voltage_l2", "voltl2": "voltage_l2", "ul2": "voltage_l2",
+	"voltagel3": "voltage_l3", "voltage3": "voltage_l3", "voltl3": "voltage_l3", "ul3": "voltage_l3",
+
+	"currentl1": "current_l1", "current1": "current_l1", "il1": "current_l1",
+	"currentl2": "current_l2", "current2": "current_l2", "il2": "current_l2",
+	"currentl3": "current_l3", "current3": "current_l3", "il3": "current_l3",
+
+	"frequency": "frequency", "freq": "frequency", "hz": "frequency",
+
+	"eint1": "e_in_t1", "ein1": "e_in_t1", "e181": "e_in_t1", "ein181": "e_in_t1",
+	"eint2": "e_in_t2", "ein2": "e_in_t2", "e182": "e_in_t2", "ein182": "e_in_t2",
+	"eoutt1": "e_out_t1", "eout1": "e_out_t1", "e281": "e_out_t1", "eout281": "e_out_t1",
+	"eoutt2": "e_out_t2", "eout2": "e_out_t2", "e282": "e_out_t2", "eout282": "e_out_t2",
+}
+
+func normalizeField(name string) string {
+	return strings.NewReplacer("_", "", "-", "", ".", "").Replace(strings.ToLower(name))
+}
+
+// wattwaechterExtras liest die optionalen Größen aus dem E320-Objekt.
+// fields (aus [wattwaechter.fields]) hat Vorrang vor den Standardnamen.
+func wattwaechterExtras(e320 map[string]json.RawMessage, fields map[string]string) map[string]float64 {
+	out := map[string]float64{}
+	for name, raw := range e320 {
+		col, ok := fields[name]
+		if !ok {
+			col, ok = wattwaechterAliases[normalizeField(name)]
+		}
+		if !ok {
+			continue
+		}
+		var v float64
+		if err := json.Unmarshal(raw, &v); err != nil {
+			continue
+		}
+		out[col] = v
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+func TestHandleWattwaechterPersistsPhasesAndTariffs(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	cfg := config.Config{
+		Time:         config.TimeConfig{Timezone: "Europe/Berlin"},
+		Wattwaechter: config.WattwaechterConfig{Fields: map[string]string{"Leistung_L3": "power_l3"}},
+	}
+	payload := `{"Time":"2025-11-24T20:00:00","E320":{"E_in":123.4,"E_out":1.2,"Power":600,
+		"Power_L1":100,"Power2":200,"Leistung_L3":300,"Voltage_L1":230.5,"Current_L2":0.9,
+		"Frequency":49.98,"E_in_1.8.1":100.4,"E_in_1.8.2":23,"Unbekannt":1}}`
+
+	handleWattwaechter("tele/WattWaechter/SENSOR", payload, db, cfg)
+
+	var p1, p2, p3, u1, i2, f, t1, t2 float64
+	err := db.QueryRow(`SELECT power_l1, power_l2, power_l3, voltage_l1, current_l2, frequency, e_in_t1, e_in_t2 FROM energy_data`).
+		Scan(&p1, &p2, &p3, &u1, &i2, &f, &t1, &t2)
+	if err != nil {
+		t.Fatalf("select energy_data: %v", err)
+	}
+	if p1 != 100 || p2 != 200 || p3 != 300 || u1 != 230.5 || i2 != 0.9 || f != 49.98 || t1 != 100.4 || t2 != 23 {
+		t.Fatalf("unexpected values %v %v %v %v %v %v %v %v", p1, p2, p3, u1, i2, f, t1, t2)
+	}
+
+	// ohne Zusatzfelder bleiben die Spalten leer
+	handleWattwaechter("tele/WattWaechter/SENSOR", `{"Time":"2025-11-24T20:01:00","E320":{"E_in":123.5,"Power":1}}`, db, cfg)
+	var missing int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM energy_data WHERE power_l1 IS NULL AND frequency IS NULL`).Scan(&missing); err != nil || missing != 1 {
+		t.Fatalf("expected one row without phase values, got %d (%v)", missing, err)
+	}
+}
@@ -0,0 +1,236 @@
+package mqtt
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	packets5 "github.com/eclipse/paho.golang/packets"
+	packets311 "github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/gorilla/websocket"
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+// wsReader liest MQTT-Pakete über WebSocket-Frames hinweg
+type wsReader struct {
+	ws *websocket.Conn
+	r  io.Reader
+}
+
+func (w *wsReader) Read(p []byte) (int, error) {
+	for {
+		if w.r == nil {
+			_, r, err := w.ws.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			w.r = r
+		}
+		n, err := w.r.Read(p)
+		if err == io.EOF {
+			w.r = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// newWSBroker startet einen minimalen Broker hinter einem WebSocket-Endpunkt:
+// er bestätigt CONNECT und SUBSCRIBE und schickt dann payload auf das
+// abonnierte Topic. Die Handshake-Header landen im Kanal.
+func newWSBroker(t *testing.T, v5, useTLS bool, payload []byte) (*httptest.Server, <-chan http.Header) {
+	t.Helper()
+	headers := make(chan http.Header, 1)
+	upgrader := websocket.Upgrader{Subprotocols: []string{"mqtt"}}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		select {
+		case headers <- r.Header.Clone():
+		default:
+		}
+
+		send := func(write func(io.Writer) error) bool {
+			var buf bytes.Buffer
+			if err := write(&buf); err != nil {
+				return false
+			}
+			return ws.WriteMessage(websocket.BinaryMessage, buf.Bytes()) == nil
+		}
+		rd := &wsReader{ws: ws}
+		if v5 {
+			serveV5(rd, send, payload)
+		} else {
+			serveV311(rd, send, payload)
+		}
+	})
+
+	if useTLS {
+		return httptest.NewTLSServer(handler), headers
+	}
+	return httptest.NewServer(handler), headers
+}
+
+func serveV311(rd io.Reader, send func(func(io.Writer) error) bool, payload []byte) {
+	for {
+		cp, err := packets311.ReadPacket(rd)
+		if err != nil {
+			return
+		}
+		var replies []packets311.ControlPacket
+		switch p := cp.(type) {
+		case *packets311.ConnectPacket:
+			replies = append(replies, packets311.NewControlPacket(packets311.Connack))
+		case *packets311.SubscribePacket:
+			ack := packets311.NewControlPacket(packets311.Suback).(*packets311.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = p.Qoss
+			pub := packets311.NewControlPacket(packets311.Publish).(*packets311.PublishPacket)
+			pub.TopicName = p.Topics[0]
+			pub.Payload = payload
+			replies = append(replies, ack, pub)
+		case *packets311.PingreqPacket:
+			replies = append(replies, packets311.NewControlPacket(packets311.Pingresp))
+		case *packets311.DisconnectPacket:
+			return
+		}
+		for _, r := range replies {
+			if !send(r.Write) {
+				return
+			}
+		}
+	}
+}
+
+func serveV5(rd io.Reader, send func(func(io.Writer) error) bool, payload []byte) {
+	write := func(cp *packets5.ControlPacket) func(io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := cp.WriteTo(w)
+			return err
+		}
+	}
+	for {
+		cp, err := packets5.ReadPacket(rd)
+		if err != nil {
+			return
+		}
+		var replies []*packets5.ControlPacket
+		switch p := cp.Content.(type) {
+		case *packets5.Connect:
+			replies = append(replies, packets5.NewControlPacket(packets5.CONNACK))
+		case *packets5.Subscribe:
+			ack := packets5.NewControlPacket(packets5.SUBACK)
+			ack.Content.(*packets5.Suback).PacketID = p.PacketID
+			ack.Content.(*packets5.Suback).Reasons = []byte{0}
+			pub := packets5.NewControlPacket(packets5.PUBLISH)
+			pub.Content.(*packets5.Publish).Topic = p.Subscriptions[0].Topic
+			pub.Content.(*packets5.Publish).Payload = payload
+			replies = append(replies, ack, pub)
+		case *packets5.Pingreq:
+			replies = append(replies, packets5.NewControlPacket(packets5.PINGRESP))
+		case *packets5.Disconnect:
+			return
+		}
+		for _, r := range replies {
+			if !send(write(r)) {
+				return
+			}
+		}
+	}
+}
+
+func TestWebSocketTransport(t *testing.T) {
+	cases := []struct {
+		name     string
+		protocol string
+		tls      bool
+	}{
+		{"3.1.1 ws", "3.1.1", false},
+		{"3.1.1 wss", "3.1.1", true},
+		{"5 ws", "5", false},
+		{"5 wss", "5", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, headers := newWSBroker(t, tc.protocol == "5", tc.tls, []byte(`{"ENERGY":{"Power":42}}`))
+			defer srv.Close()
+
+			// http:// -> ws://, https:// -> wss://
+			b := config.BrokerConfig{
+				Host:     "ws" + strings.TrimPrefix(srv.URL, "http") + "/mqtt",
+				ClientID: "ws-test",
+				Protocol: tc.protocol,
+				Headers:  map[string]string{"Authorization": "Bearer geheim"},
+				Proxy:    "none",
+				TLS:      config.TLSConfig{InsecureSkipVerify: tc.tls},
+			}
+
+			got := make(chan Message, 1)
+			c, err := connect(clientOptions{
+				Broker:         b,
+				ClientID:       b.ClientID,
+				ConnectTimeout: 2 * time.Second,
+				Subscriptions: []Subscription{{Topic: "tele/plug/SENSOR", Handler: func(m Message) {
+					got <- m
+				}}},
+			})
+			if err != nil {
+				t.Fatalf("connect %s: %v", b.Host, err)
+			}
+			defer c.Disconnect()
+
+			select {
+			case h := <-headers:
+				if h.Get("Authorization") != "Bearer geheim" {
+					t.Fatalf("missing auth header: %v", h)
+				}
+				if h.Get("Sec-Websocket-Protocol") != "mqtt" {
+					t.Fatalf("unexpected subprotocol: %q", h.Get("Sec-Websocket-Protocol"))
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("no websocket handshake")
+			}
+
+			select {
+			case m := <-got:
+				if m.Topic != "tele/plug/SENSOR" || string(m.Payload) != `{"ENERGY":{"Power":42}}` {
+					t.Fatalf("unexpected message: %s %s", m.Topic, m.Payload)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("no message received over websocket")
+			}
+		})
+	}
+}
+
+func TestWebSocketProxy(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://broker.example.com/mqtt", nil)
+
+	p, err := wsProxy("http://proxy.local:3128")
+	if err != nil {
+		t.Fatalf("wsProxy: %v", err)
+	}
+	if u, _ := p(req); u == nil || u.Host != "proxy.local:3128" {
+		t.Fatalf("unexpected proxy: %v", u)
+	}
+
+	p, _ = wsProxy("none")
+	if u, _ := p(req); u != nil {
+		t.Fatalf("expected direct connection, got %v", u)
+	}
+
+	if _, err := wsProxy("://kaputt"); err == nil {
+		t.Fatal("expected error for invalid proxy url")
+	}
+}
@@ -0,0 +1,179 @@
+package mqtt
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+	"github.com/khorsmann/mqttlogger/internal/health"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+	"github.com/khorsmann/mqttlogger/internal/stream"
+)
+
+// -------------------------------------------------------------------
+// Zigbee2MQTT: <base>/<friendly_name> als JSON, Geräteliste aus
+// <base>/bridge/devices
+// -------------------------------------------------------------------
+
+// zigbeeReading sind die Messwerte einer Zigbee2MQTT-Nachricht; nicht
+// gemeldete Werte bleiben nil
+type zigbeeReading struct {
+	Power   *float64 `json:"power"`   // W
+	Energy  *float64 `json:"energy"`  // kWh
+	Voltage *float64 `json:"voltage"` // V
+	Current *float64 `json:"current"` // A
+	// LastSeen ist gesetzt, wenn in Zigbee2MQTT last_seen = ISO_8601 aktiv ist
+	LastSeen string `json:"last_seen"`
+}
+
+func (r zigbeeReading) empty() bool {
+	return r.Power == nil && r.Energy == nil && r.Voltage == nil && r.Current == nil
+}
+
+// zigbeeBase liefert das Basis-Topic aus dem Abo (z.B. "zigbee2mqtt/#")
+func zigbeeBase(subscription string) string {
+	base := strings.TrimSuffix(subscription, "/#")
+	return strings.TrimSuffix(base, "/+")
+}
+
+// zigbeeDevice liefert den friendly_name aus dem Topic. Bridge-Topics und
+// Unter-Topics wie /availability, /set oder /get sind keine Messwerte.
+func zigbeeDevice(base, topic string) (name string, bridge, ok bool) {
+	rest, found := strings.CutPrefix(topic, base+"/")
+	if !found || rest == "" {
+		return "", false, false
+	}
+	if strings.HasPrefix(rest, "bridge/") {
+		return rest, true, false
+	}
+	// friendly_name darf selbst "/" enthalten
+	switch rest[strings.LastIndex(rest, "/")+1:] {
+	case "availability", "set", "get":
+		return "", false, false
+	}
+	return rest, false, true
+}
+
+// decodeZigbee liest power, energy, voltage und current aus dem Payload
+func decodeZigbee(payload string) (zigbeeReading, error) {
+	var r zigbeeReading
+	if !strings.HasPrefix(strings.TrimSpace(payload), "{") {
+		return r, fmt.Errorf("kein JSON-Objekt")
+	}
+	err := json.Unmarshal([]byte(payload), &r)
+	return r, err
+}
+
+func handleZigbee(topic, payload string, dbh *sql.DB, cfg config.Config) {
+	device, bridge, ok := zigbeeDevice(zigbeeBase(cfg.Broker.Topics.Zigbee2MQTT), topic)
+	if bridge && device == "bridge/devices" {
+		handleZigbeeDevices(payload, dbh, cfg)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	r, err := decodeZigbee(payload)
+	if err != nil {
+		if cfg.Broker.SetDebug {
+			log.Printf("[Zigbee] %s ignoriert: %v", topic, err)
+		}
+		return
+	}
+	if r.empty() {
+		// Sensoren ohne Leistungswerte (Temperatur, Kontakte, ...)
+		return
+	}
+	metrics.MessagesReceived.Inc("zigbee")
+
+	var dev time.Time
+	if r.LastSeen != "" {
+		dev = deviceTime(cfg, "zigbee", r.LastSeen)
+	}
+	t := resolveTime(cfg, "zigbee", device, dev, time.Now())
+	t = t.In(cfg.Time.Location())
+
+	stored, err := storeReading(dbh, cfg, "zigbee", "zigbee_data",
+		[]string{"device_id", "timestamp_unix", "timestamp_rfc3339", "power", "energy_total", "voltage", "current"},
+		device, t.Unix(), t.Format(time.RFC3339), r.Power, r.Energy, r.Voltage, r.Current)
+	if err != nil {
+		log.Printf("[Zigbee] DB Insert Fehler: %v", err)
+		metrics.Errors.Inc("zigbee", "db")
+		return
+	}
+	if !stored {
+		return
+	}
+
+	metrics.MessagesStored.Inc("zigbee")
+	health.Default.Seen("zigbee")
+	touchDevice(dbh, "zigbee", device, t)
+	for metric, v := range map[string]*float64{"power": r.Power, "energy_total": r.Energy, "voltage": r.Voltage, "current": r.Current} {
+		if v == nil {
+			continue
+		}
+		if metric == "power" {
+			metrics.ZigbeePower.Set(*v, device)
+		}
+		stream.Default.Publish(stream.Reading{Source: "zigbee", Device: device, Metric: metric, Value: *v, Time: t})
+	}
+	if cfg.Broker.SetDebug {
+		log.Printf("[Zigbee] %s gespeichert", device)
+	}
+}
+
+// handleZigbeeDevices übernimmt Modell, Hersteller und IEEE-Adresse aus
+// <base>/bridge/devices ins Geräteregister
+func handleZigbeeDevices(payload string, dbh *sql.DB, cfg config.Config) {
+	var devices []struct {
+		IEEEAddress  string `json:"ieee_address"`
+		FriendlyName string `json:"friendly_name"`
+		Type         string `json:"type"`
+		PowerSource  string `json:"power_source"`
+		ModelID      string `json:"model_id"`
+		Manufacturer string `json:"manufacturer"`
+		Definition   *struct {
+			Model       string `json:"model"`
+			Vendor      string `json:"vendor"`
+			Description string `json:"description"`
+		} `json:"definition"`
+	}
+	if err := json.Unmarshal([]byte(payload), &devices); err != nil {
+		log.Printf("[Zigbee] bridge/devices JSON Fehler: %v", err)
+		metrics.Errors.Inc("zigbee", "json")
+		return
+	}
+
+	n := 0
+	for _, d := range devices {
+		if d.Type == "Coordinator" || d.FriendlyName == "" {
+			continue
+		}
+		info := map[string]string{
+			"ieee_address": d.IEEEAddress,
+			"type":         d.Type,
+			"power_source": d.PowerSource,
+			"model":        d.ModelID,
+			"vendor":       d.Manufacturer,
+		}
+		if d.Definition != nil {
+			info["model"], info["vendor"], info["description"] = d.Definition.Model, d.Definition.Vendor, d.Definition.Description
+		}
+		raw, _ := json.Marshal(info)
+		if err := db.SetDeviceInfo(dbh, "zigbee", d.FriendlyName, raw); err != nil {
+			log.Printf("[Zigbee] DB-Fehler Geräteliste %s: %v", d.FriendlyName, err)
+			metrics.Errors.Inc("zigbee", "db")
+			return
+		}
+		n++
+	}
+	if cfg.Broker.SetDebug {
+		log.Printf("[Zigbee] Geräteliste: %d Geräte übernommen", n)
+	}
+}
@@ -0,0 +1,70 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+func TestZigbeeDevice(t *testing.T) {
+	tests := []struct {
+		topic  string
+		name   string
+		bridge bool
+		ok     bool
+	}{
+		{"zigbee2mqtt/Waschmaschine", "Waschmaschine", false, true},
+		{"zigbee2mqtt/Keller/Trockner", "Keller/Trockner", false, true},
+		{"zigbee2mqtt/Waschmaschine/availability", "", false, false},
+		{"zigbee2mqtt/Waschmaschine/set", "", false, false},
+		{"zigbee2mqtt/bridge/devices", "bridge/devices", true, false},
+		{"other/Waschmaschine", "", false, false},
+	}
+	for _, tt := range tests {
+		name, bridge, ok := zigbeeDevice(zigbeeBase("zigbee2mqtt/#"), tt.topic)
+		if name != tt.name || bridge != tt.bridge || ok != tt.ok {
+			t.Errorf("%s: got (%q, %v, %v), want (%q, %v, %v)", tt.topic, name, bridge, ok, tt.name, tt.bridge, tt.ok)
+		}
+	}
+}
+
+func TestHandleZigbeePersistsPlugAndRegistry(t *testing.T) {
+	db := newMQTTTestDB(t)
+	defer db.Close()
+
+	cfg := config.Config{Time: config.TimeConfig{Timezone: "Europe/Berlin"}}
+	cfg.Broker.Topics.Zigbee2MQTT = "zigbee2mqtt/#"
+
+	handleZigbee("zigbee2mqtt/bridge/devices", `[
+		{"ieee_address":"0x00","type":"Coordinator","friendly_name":"Coordinator"},
+		{"ieee_address":"0x01","type":"Router","friendly_name":"Waschmaschine","power_source":"Mains (single phase)",
+		 "definition":{"model":"SP 120","vendor":"innr","description":"Smart plug"}}
+	]`, db, cfg)
+	handleZigbee("zigbee2mqtt/Waschmaschine",
+		`{"power":1850.5,"energy":12.75,"voltage":229,"current":8.1,"state":"ON","last_seen":"2025-11-24T19:00:00Z"}`, db, cfg)
+	handleZigbee("zigbee2mqtt/Flur", `{"temperature":21.5,"humidity":40}`, db, cfg)
+	handleZigbee("zigbee2mqtt/Waschmaschine/availability", `{"state":"online"}`, db, cfg)
+
+	var rows int
+	var ts int64
+	var rfc3339 string
+	var power, energy float64
+	if err := db.QueryRow(`SELECT COUNT(*), timestamp_unix, timestamp_rfc3339, power, energy_total FROM zigbee_data`).Scan(&rows, &ts, &rfc3339, &power, &energy); err != nil {
+		t.Fatalf("select zigbee_data: %v", err)
+	}
+	if rows != 1 || ts != 1764010800 || power != 1850.5 || energy != 12.75 {
+		t.Fatalf("rows=%d ts=%d power=%v energy=%v", rows, ts, power, energy)
+	}
+	if rfc3339 != "2025-11-24T20:00:00+01:00" {
+		t.Fatalf("timestamp_rfc3339 = %s, want Europe/Berlin", rfc3339)
+	}
+
+	var model string
+	var devices int
+	if err := db.QueryRow(`SELECT COUNT(*), json_extract(info, '$.model') FROM devices WHERE source = 'zigbee'`).Scan(&devices, &model); err != nil {
+		t.Fatalf("select devices: %v", err)
+	}
+	if devices != 1 || model != "SP 120" {
+		t.Fatalf("devices=%d model=%q", devices, model)
+	}
+}
@@ -0,0 +1,161 @@
+package sml
+
+import (
+	"bufio"
+	"bytes"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// -------------------------------------------------------------------
+// D0 / IEC 62056-21: Textzeilen wie "1-0:1.8.0*255(001234.5678*kWh)"
+// -------------------------------------------------------------------
+
+// A-B: und *F sind optional ("1.8.0(...)")
+var d0Line = regexp.MustCompile(`^(?:(\d+)-(\d+):)?(\d+)\.(\d+)\.(\d+)(?:\*(\d+))?\(([^)]*)\)`)
+
+// DecodeD0 dekodiert ein D0-Telegramm. Die Kennungszeile ("/ESY5...") und
+// das Endezeichen "!" sind optional.
+func DecodeD0(payload []byte) (Reading, error) {
+	r := Reading{Values: map[string]Value{}}
+
+	sc := bufio.NewScanner(bytes.NewReader(payload))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "!" {
+			break
+		}
+		m := d0Line.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		a, b, f := m[1], m[2], m[6]
+		if a == "" {
+			a, b = "1", "0"
+		}
+		if f == "" {
+			f = "255"
+		}
+		code := m[3] + "." + m[4] + "." + m[5]
+		key := a + "-" + b + ":" + code + "*" + f
+
+		raw, unit, _ := strings.Cut(m[7], "*")
+		if code == "96.1.0" || code == "0.0.0" {
+			r.MeterID = raw
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			continue
+		}
+		r.Values[key] = Value{Value: v, Unit: unit}
+	}
+	if err := sc.Err(); err != nil {
+		return Reading{}, err
+	}
+	if err := r.derive(); err != nil {
+		return Reading{}, err
+	}
+	return r, nil
+}
+
+// -------------------------------------------------------------------
+// OBIS-Kennzahlen -> Bezug, Einspeisung, Leistung
+// -------------------------------------------------------------------
+
+// value sucht eine Kennzahl C.D.E unabhängig von A-B und F. Gibt es sie
+// mehrfach (z.B. *255 und Vorwerte *96), gewinnt der aktuelle Wert *255,
+// sonst der erste Schlüssel in sortierter Reihenfolge.
+func (r Reading) value(code string) (Value, bool) {
+	found := ""
+	for _, key := range slices.Sorted(maps.Keys(r.Values)) {
+		if !strings.HasPrefix(key, "1-") || obisCode(key) != code {
+			continue
+		}
+		if strings.HasSuffix(key, "*255") {
+			return r.Values[key], true
+		}
+		if found == "" {
+			found = key
+		}
+	}
+	if found == "" {
+		return Value{}, false
+	}
+	return r.Values[found], true
+}
+
+// obisCode liefert C.D.E aus "A-B:C.D.E*F"
+func obisCode(key string) string {
+	_, rest, _ := strings.Cut(key, ":")
+	code, _, _ := strings.Cut(rest, "*")
+	return code
+}
+
+// energy liefert den Zählerstand in kWh; ohne Gesamtregister (x.8.0)
+// wird über die Tarifregister x.8.1, x.8.2, ... summiert
+func (r Reading) energy(c string) *float64 {
+	if v, ok := r.value(c + ".8.0"); ok {
+		return kwh(v)
+	}
+	var sum *float64
+	for t := 1; t <= 9; t++ {
+		v, ok := r.value(c + ".8." + strconv.Itoa(t))
+		if !ok {
+			continue
+		}
+		if sum == nil {
+			sum = new(float64)
+		}
+		*sum += *kwh(v)
+	}
+	return sum
+}
+
+func kwh(v Value) *float64 {
+	x := v.Value
+	if strings.EqualFold(v.Unit, "Wh") {
+		x /= 1000
+	}
+	return &x
+}
+
+func watts(v Value) *float64 {
+	x := v.Value
+	if strings.EqualFold(v.Unit, "kW") {
+		x *= 1000
+	}
+	return &x
+}
+
+// inPower liefert die Bezugsleistung; EasyMeter melden die Summe aller
+// Phasen als 1.7.255 statt 1.7.0
+func (r Reading) inPower() (Value, bool) {
+	if v, ok := r.value("1.7.0"); ok {
+		return v, true
+	}
+	return r.value("1.7.255")
+}
+
+// derive setzt EIn, EOut und Power aus den OBIS-Werten
+func (r *Reading) derive() error {
+	r.EIn = r.energy("1")
+	r.EOut = r.energy("2")
+	if v, ok := r.value("16.7.0"); ok {
+		r.Power = watts(v)
+	} else if in, ok := r.inPower(); ok {
+		// Saldo aus Bezugs- und Einspeiseleistung
+		p := *watts(in)
+		if out, ok := r.value("2.7.0"); ok {
+			p -= *watts(out)
+		}
+		r.Power = &p
+	}
+	if r.EIn == nil && r.EOut == nil && r.Power == nil {
+		return ErrNoData
+	}
+	return nil
+}
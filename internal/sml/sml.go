@@ -0,0 +1,345 @@
+// Package sml dekodiert Zählertelegramme von IR-Lesköpfen: binäres SML
+// (Smart Message Language, Transport v1) und D0-Text nach IEC 62056-21.
+package sml
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// -------------------------------------------------------------------
+// Ergebnis
+// -------------------------------------------------------------------
+
+// Value ist ein OBIS-Wert mit Einheit (z.B. "Wh", "W", "kWh")
+type Value struct {
+	Value float64
+	Unit  string
+}
+
+// Reading ist ein dekodiertes Telegramm. EIn/EOut (kWh) und Power (W) sind
+// nil, wenn der Zähler sie nicht liefert.
+type Reading struct {
+	MeterID string
+	EIn     *float64
+	EOut    *float64
+	Power   *float64
+	// Values enthält alle Werte, Schlüssel wie "1-0:1.8.0*255"
+	Values map[string]Value
+}
+
+var (
+	escape    = []byte{0x1b, 0x1b, 0x1b, 0x1b}
+	frameOpen = []byte{0x1b, 0x1b, 0x1b, 0x1b, 0x01, 0x01, 0x01, 0x01}
+
+	ErrNoFrame = errors.New("kein vollständiges SML-Telegramm")
+	ErrCRC     = errors.New("SML-Prüfsumme falsch")
+	ErrNoData  = errors.New("keine Zählerwerte im Telegramm")
+)
+
+// Decode erkennt binäres SML, SML als Hex-Text und D0-Text
+func Decode(payload []byte) (Reading, error) {
+	if bytes.Contains(payload, frameOpen) {
+		return DecodeSML(payload)
+	}
+	if raw, ok := hexFrame(payload); ok {
+		return DecodeSML(raw)
+	}
+	return DecodeD0(payload)
+}
+
+// hexFrame dekodiert "1B1B1B1B01010101..." (Leerzeichen erlaubt)
+func hexFrame(payload []byte) ([]byte, bool) {
+	compact := make([]byte, 0, len(payload))
+	for _, c := range payload {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		compact = append(compact, c)
+	}
+	if len(compact) < 16 || !bytes.EqualFold(compact[:16], []byte("1b1b1b1b01010101")) {
+		return nil, false
+	}
+	raw, err := hex.DecodeString(string(compact))
+	return raw, err == nil
+}
+
+// -------------------------------------------------------------------
+// Transportschicht: Escape-Sequenzen, Füllbytes, CRC16/X.25
+// -------------------------------------------------------------------
+
+// DecodeSML dekodiert das erste vollständige SML-Telegramm im Puffer
+func DecodeSML(buf []byte) (Reading, error) {
+	body, err := unframe(buf)
+	if err != nil {
+		return Reading{}, err
+	}
+
+	r := Reading{Values: map[string]Value{}}
+	for len(body) > 0 {
+		// Füllbytes und Nachrichtenende zwischen den Nachrichten
+		if body[0] == 0x00 {
+			body = body[1:]
+			continue
+		}
+		var msg node
+		if msg, body, err = parseNode(body, 0); err != nil {
+			return Reading{}, err
+		}
+		collectMessage(msg, &r)
+	}
+	if err := r.derive(); err != nil {
+		return Reading{}, err
+	}
+	return r, nil
+}
+
+// unframe prüft die CRC und liefert die Nachrichten ohne Escape-Sequenzen
+// und Füllbytes
+func unframe(buf []byte) ([]byte, error) {
+	start := bytes.Index(buf, frameOpen)
+	if start < 0 {
+		return nil, ErrNoFrame
+	}
+
+	var body []byte
+	for i := start + len(frameOpen); i+4 <= len(buf); i += 4 {
+		if !bytes.Equal(buf[i:i+4], escape) {
+			body = append(body, buf[i:i+4]...)
+			continue
+		}
+		if i+8 > len(buf) {
+			return nil, ErrNoFrame
+		}
+		switch {
+		case bytes.Equal(buf[i+4:i+8], escape):
+			// maskierte Escape-Sequenz in den Nutzdaten
+			body = append(body, escape...)
+			i += 4
+		case buf[i+4] == 0x1a:
+			frame := buf[start : i+8]
+			want := uint16(frame[len(frame)-2]) | uint16(frame[len(frame)-1])<<8
+			if crc16(frame[:len(frame)-2]) != want {
+				return nil, ErrCRC
+			}
+			fill := int(buf[i+5])
+			if fill > 3 || fill > len(body) {
+				return nil, fmt.Errorf("SML: ungültige Füllbytes (%d)", fill)
+			}
+			return body[:len(body)-fill], nil
+		default:
+			return nil, fmt.Errorf("SML: unbekannte Escape-Sequenz % x", buf[i+4:i+8])
+		}
+	}
+	return nil, ErrNoFrame
+}
+
+// crc16 ist CRC-16/X.25 (Polynom 0x1021 reflektiert, wie libsml)
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for range 8 {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0x8408
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
+
+// -------------------------------------------------------------------
+// TL-Kodierung (Type-Length-Field)
+// -------------------------------------------------------------------
+
+const (
+	typeOctet    = 0x00
+	typeBool     = 0x40
+	typeInt      = 0x50
+	typeUnsigned = 0x60
+	typeList     = 0x70
+
+	maxDepth = 16
+)
+
+type node struct {
+	typ   byte
+	empty bool // "optional, nicht gesetzt" (0x01)
+	bytes []byte
+	num   int64
+	unum  uint64
+	list  []node
+}
+
+func parseNode(b []byte, depth int) (node, []byte, error) {
+	if depth > maxDepth {
+		return node{}, nil, errors.New("SML: Verschachtelung zu tief")
+	}
+	if len(b) == 0 {
+		return node{}, nil, errors.New("SML: unerwartetes Ende")
+	}
+
+	tl := b[0]
+	if tl == 0x00 {
+		// endOfSmlMsg
+		return node{empty: true}, b[1:], nil
+	}
+	n := node{typ: tl & 0x70}
+	length := int(tl & 0x0f)
+	size := 1
+	for tl&0x80 != 0 {
+		if size >= len(b) || size > 4 {
+			return node{}, nil, errors.New("SML: ungültiges TL-Feld")
+		}
+		tl = b[size]
+		length = length<<4 | int(tl&0x0f)
+		size++
+	}
+	b = b[size:]
+
+	if n.typ == typeList {
+		for range length {
+			child, rest, err := parseNode(b, depth+1)
+			if err != nil {
+				return node{}, nil, err
+			}
+			n.list = append(n.list, child)
+			b = rest
+		}
+		return n, b, nil
+	}
+
+	// bei allen anderen Typen zählt das TL-Feld zur Länge
+	dataLen := length - size
+	if dataLen < 0 || dataLen > len(b) {
+		return node{}, nil, errors.New("SML: Länge außerhalb des Telegramms")
+	}
+	data := b[:dataLen]
+	b = b[dataLen:]
+	if dataLen == 0 {
+		n.empty = true
+		return n, b, nil
+	}
+
+	switch n.typ {
+	case typeOctet:
+		n.bytes = data
+	case typeBool:
+		n.unum = uint64(data[0])
+	case typeInt, typeUnsigned:
+		if dataLen > 8 {
+			return node{}, nil, errors.New("SML: Zahl länger als 8 Byte")
+		}
+		for _, c := range data {
+			n.unum = n.unum<<8 | uint64(c)
+		}
+		n.num = int64(n.unum)
+		if n.typ == typeInt && dataLen < 8 && data[0]&0x80 != 0 {
+			// Vorzeichen erweitern
+			n.num -= 1 << (8 * dataLen)
+		}
+	default:
+		return node{}, nil, fmt.Errorf("SML: unbekannter Typ 0x%02x", n.typ)
+	}
+	return n, b, nil
+}
+
+func (n node) number() (float64, bool) {
+	switch {
+	case n.empty:
+		return 0, false
+	case n.typ == typeInt:
+		return float64(n.num), true
+	case n.typ == typeUnsigned:
+		return float64(n.unum), true
+	}
+	return 0, false
+}
+
+// -------------------------------------------------------------------
+// Nachrichten: nur SML_GetList.Res (0x0701) enthält Zählerwerte
+// -------------------------------------------------------------------
+
+const getListResponse = 0x0701
+
+func collectMessage(msg node, r *Reading) {
+	// SML_Message: transactionId, groupNo, abortOnError, messageBody, crc16, endOfSmlMsg
+	if msg.typ != typeList || len(msg.list) < 4 {
+		return
+	}
+	body := msg.list[3]
+	if body.typ != typeList || len(body.list) != 2 || body.list[0].unum != getListResponse {
+		return
+	}
+	// SML_GetList.Res: clientId, serverId, listName, actSensorTime, valList, ...
+	res := body.list[1]
+	if res.typ != typeList || len(res.list) < 5 {
+		return
+	}
+	if id := res.list[1].bytes; len(id) > 0 && r.MeterID == "" {
+		r.MeterID = hex.EncodeToString(id)
+	}
+	for _, e := range res.list[4].list {
+		// SML_ListEntry: objName, status, valTime, unit, scaler, value, valueSignature
+		if e.typ != typeList || len(e.list) < 6 || len(e.list[0].bytes) != 6 {
+			continue
+		}
+		o := e.list[0].bytes
+		key := fmt.Sprintf("%d-%d:%d.%d.%d*%d", o[0], o[1], o[2], o[3], o[4], o[5])
+
+		if e.list[5].typ == typeOctet {
+			// Zählernummer (96.1.0 bzw. 0.0.0) als Text
+			if (o[2] == 96 && o[3] == 1 && o[4] == 0) || (o[2] == 0 && o[3] == 0 && o[4] == 0) {
+				r.MeterID = printableID(e.list[5].bytes)
+			}
+			continue
+		}
+		v, ok := e.list[5].number()
+		if !ok {
+			continue
+		}
+		if s, ok := e.list[4].number(); ok {
+			v *= math.Pow10(int(s))
+		}
+		r.Values[key] = Value{Value: v, Unit: unitName(e.list[3].unum)}
+	}
+}
+
+// printableID liefert die Zählernummer als Text oder, falls binär, als Hex
+func printableID(b []byte) string {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return hex.EncodeToString(b)
+		}
+	}
+	return string(b)
+}
+
+// unitName übersetzt DLMS-Einheitencodes
+func unitName(code uint64) string {
+	switch code {
+	case 27:
+		return "W"
+	case 28:
+		return "VA"
+	case 29:
+		return "var"
+	case 30:
+		return "Wh"
+	case 32:
+		return "varh"
+	case 33:
+		return "A"
+	case 35:
+		return "V"
+	case 44:
+		return "Hz"
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package sml
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fixture(t testing.TB, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return b
+}
+
+func near(v *float64, want float64) bool {
+	return v != nil && math.Abs(*v-want) < 1e-6
+}
+
+func TestDecodeFixtures(t *testing.T) {
+	tests := []struct {
+		file             string
+		meter            string
+		eIn, eOut, power float64
+		values           int
+	}{
+		{"ehz_getlist.bin", "0a01454d4800009f4b29", 12345.6789, 1.2345, 456, 3},
+		{"tariffs_hex.txt", "06495", 2000, 250, -1234.56, 5},
+		{"escaped.bin", "1b1b1b1b", 42, 0, 0, 1},
+		{"d0_esy.txt", "1ESY1160123456", 12345.6789, 123.4, 456.78, 4},
+		{"d0_short.txt", "12345678", 5321.123, 10.5, 1250, 5},
+		{"d0_easymeter_q3d.txt", "1ESY1161234567", 4286.7425131, 0, 268.7, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			r, err := Decode(fixture(t, tt.file))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if len(r.MeterID) < len(tt.meter) || r.MeterID[:len(tt.meter)] != tt.meter {
+				t.Errorf("meter = %q, want prefix %q", r.MeterID, tt.meter)
+			}
+			if !near(r.EIn, tt.eIn) {
+				t.Errorf("EIn = %v, want %v", deref(r.EIn), tt.eIn)
+			}
+			if tt.eOut != 0 && !near(r.EOut, tt.eOut) {
+				t.Errorf("EOut = %v, want %v", deref(r.EOut), tt.eOut)
+			}
+			if tt.power != 0 && !near(r.Power, tt.power) {
+				t.Errorf("Power = %v, want %v", deref(r.Power), tt.power)
+			}
+			if len(r.Values) != tt.values {
+				t.Errorf("values = %v, want %d entries", r.Values, tt.values)
+			}
+		})
+	}
+}
+
+func TestD0PrefersCurrentValue(t *testing.T) {
+	// Vorwerte (*96, *97) neben dem aktuellen Stand *255; Map-Reihenfolge
+	// darf das Ergebnis nicht beeinflussen
+	payload := []byte("1-0:1.8.0*96(000100.0*kWh)\n1-0:1.8.0*255(000123.4*kWh)\n1-0:1.8.0*97(000090.0*kWh)\n" +
+		"1-0:2.8.0*97(000002.0*kWh)\n1-0:2.8.0*96(000001.0*kWh)\n")
+	for range 20 {
+		r, err := DecodeD0(payload)
+		if err != nil {
+			t.Fatalf("DecodeD0: %v", err)
+		}
+		if !near(r.EIn, 123.4) {
+			t.Fatalf("EIn = %v, want 123.4 (*255)", deref(r.EIn))
+		}
+		if !near(r.EOut, 1.0) {
+			t.Fatalf("EOut = %v, want 1.0 (first sorted key *96)", deref(r.EOut))
+		}
+	}
+}
+
+func deref(v *float64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func TestDecodeSMLRejectsBadCRC(t *testing.T) {
+	b := fixture(t, "ehz_getlist.bin")
+	b[40] ^= 0xff
+	if _, err := DecodeSML(b); !errors.Is(err, ErrCRC) {
+		t.Fatalf("err = %v, want ErrCRC", err)
+	}
+	if _, err := DecodeSML(b[:len(b)-10]); !errors.Is(err, ErrNoFrame) {
+		t.Fatalf("truncated: err = %v, want ErrNoFrame", err)
+	}
+}
+
+func TestCRC16X25(t *testing.T) {
+	if got := crc16([]byte("123456789")); got != 0x906e {
+		t.Fatalf("crc16 = %#04x, want 0x906e", got)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	entries, err := os.ReadDir("testdata")
+	if err != nil {
+		f.Fatalf("read testdata: %v", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			f.Add(fixture(f, e.Name()))
+		}
+	}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		r, err := Decode(payload)
+		if err != nil {
+			return
+		}
+		if r.EIn == nil && r.EOut == nil && r.Power == nil {
+			t.Fatalf("Decode ohne Fehler, aber ohne Werte: %+v", r)
+		}
+	})
+}
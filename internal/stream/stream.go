@@ -0,0 +1,119 @@
+package stream
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reading ist ein einzelner dekodierter Messwert
+type Reading struct {
+	Source string    `json:"source"`
+	Device string    `json:"device"`
+	Metric string    `json:"metric"`
+	Value  float64   `json:"value"`
+	Time   time.Time `json:"time"`
+}
+
+// Filter beschränkt einen Abonnenten auf bestimmte Quellen/Geräte/Metriken.
+// Leere Listen bedeuten "alles".
+type Filter struct {
+	Sources []string
+	Devices []string
+	Metrics []string
+}
+
+// ParseList zerlegt eine kommagetrennte Liste (z.B. aus Query-Parametern)
+func ParseList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Match prüft, ob ein Messwert den Filter passiert
+func (f Filter) Match(r Reading) bool {
+	return matchAny(f.Sources, r.Source) && matchAny(f.Devices, r.Device) && matchAny(f.Metrics, r.Metric)
+}
+
+func matchAny(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
+
+// -------------------------------------------------------------------
+// Hub – verteilt Messwerte an alle Abonnenten
+// -------------------------------------------------------------------
+
+// Hub verteilt Messwerte an Abonnenten. Langsame Abonnenten verlieren
+// Werte, damit die MQTT-Handler nie blockieren.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription ist ein einzelner Abonnent
+type Subscription struct {
+	C      chan Reading
+	filter Filter
+	hub    *Hub
+}
+
+// NewHub erstellt einen leeren Hub
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscription]struct{}{}}
+}
+
+// Default ist der Hub des laufenden Prozesses
+var Default = NewHub()
+
+// Subscribe registriert einen Abonnenten mit Puffer
+func (h *Hub) Subscribe(f Filter, buffer int) *Subscription {
+	s := &Subscription{C: make(chan Reading, buffer), filter: f, hub: h}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Close meldet den Abonnenten ab
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	if _, ok := s.hub.subs[s]; ok {
+		delete(s.hub.subs, s)
+		close(s.C)
+	}
+	s.hub.mu.Unlock()
+}
+
+// Publish verteilt einen Messwert, ohne zu blockieren
+func (h *Hub) Publish(r Reading) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if !s.filter.Match(r) {
+			continue
+		}
+		select {
+		case s.C <- r:
+		default:
+		}
+	}
+}
+
+// Subscribers liefert die Anzahl der Abonnenten
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
@@ -0,0 +1,54 @@
+package stream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishRespectsFilter(t *testing.T) {
+	h := NewHub()
+	all := h.Subscribe(Filter{}, 4)
+	plug := h.Subscribe(Filter{Devices: ParseList("plug1, plug2"), Metrics: []string{"power"}}, 4)
+	defer all.Close()
+	defer plug.Close()
+
+	now := time.Now()
+	h.Publish(Reading{Source: "tasmota", Device: "plug1", Metric: "power", Value: 10, Time: now})
+	h.Publish(Reading{Source: "tasmota", Device: "plug3", Metric: "power", Value: 20, Time: now})
+	h.Publish(Reading{Source: "solar", Device: "plug2", Metric: "yield", Value: 30, Time: now})
+
+	if len(all.C) != 3 {
+		t.Fatalf("expected 3 readings for unfiltered subscriber, got %d", len(all.C))
+	}
+	if len(plug.C) != 1 {
+		t.Fatalf("expected 1 reading for filtered subscriber, got %d", len(plug.C))
+	}
+	if r := <-plug.C; r.Device != "plug1" || r.Value != 10 {
+		t.Fatalf("unexpected reading: %+v", r)
+	}
+}
+
+func TestPublishDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	h := NewHub()
+	sub := h.Subscribe(Filter{}, 1)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			h.Publish(Reading{Metric: "power", Value: float64(i)})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on full subscriber")
+	}
+
+	sub.Close()
+	sub.Close() // doppeltes Close darf nicht paniken
+	if h.Subscribers() != 0 {
+		t.Fatalf("expected no subscribers, got %d", h.Subscribers())
+	}
+}
@@ -0,0 +1,21 @@
+package version
+
+import "runtime/debug"
+
+// Version wird beim Build per -ldflags "-X ...version.Version=..." gesetzt
+var Version = "dev"
+
+// String liefert die Version, ersatzweise die VCS-Revision aus den Build-Infos
+func String() string {
+	if Version != "dev" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && len(s.Value) >= 7 {
+				return "dev-" + s.Value[:7]
+			}
+		}
+	}
+	return Version
+}
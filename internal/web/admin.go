@@ -0,0 +1,85 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/db"
+)
+
+// -------------------------------------------------------------------
+// Admin-Endpunkte (Scope "admin")
+// -------------------------------------------------------------------
+
+var backupMu sync.Mutex
+
+// POST /api/admin/backup – legt ein Backup in [database] backup_dir an
+func (s *Server) handleBackup(w http.ResponseWriter, _ *http.Request) {
+	if !backupMu.TryLock() {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Backup läuft bereits"})
+		return
+	}
+	defer backupMu.Unlock()
+
+	dir := s.cfg.Database.BackupDir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(s.cfg.Database.Path), "backups")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("mqttlogger-%s.db", time.Now().Format("20060102-150405")))
+	if err := db.SnapshotBackup(s.db, path); err != nil {
+		s.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"path": path})
+}
+
+// POST /api/admin/aggregate – führt alle Aggregationen sofort aus
+func (s *Server) handleAggregate(w http.ResponseWriter, _ *http.Request) {
+	start := time.Now()
+	db.RunAggregations(s.db, s.cfg)
+	writeJSON(w, http.StatusOK, map[string]any{"duration_ms": time.Since(start).Milliseconds()})
+}
+
+type tariff struct {
+	PerKWh float64 `json:"per_kwh"`
+}
+
+// GET /api/tariff – aktueller Preis pro kWh
+func (s *Server) handleGetTariff(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, tariff{PerKWh: db.Tariff(s.db, s.cfg.Cost.PerKWh)})
+}
+
+// PUT /api/admin/tariff – neuen Preis setzen und Kosten neu berechnen
+func (s *Server) handleSetTariff(w http.ResponseWriter, r *http.Request) {
+	// Zeiger und DisallowUnknownFields: {} oder ein Tippfehler im Feldnamen
+	// darf den Tarif nicht auf 0 setzen
+	var body struct {
+		PerKWh *float64 `json:"per_kwh"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if body.PerKWh == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "per_kwh fehlt"})
+		return
+	}
+	if err := db.SetTariff(s.db, *body.PerKWh); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	db.RunAggregations(s.db, s.cfg)
+	writeJSON(w, http.StatusOK, tariff{PerKWh: *body.PerKWh})
+}
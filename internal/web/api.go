@@ -0,0 +1,117 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/db"
+	"github.com/khorsmann/mqttlogger/internal/health"
+)
+
+// -------------------------------------------------------------------
+// JSON-API für das Dashboard
+// -------------------------------------------------------------------
+
+func (s *Server) handleLive(w http.ResponseWriter, _ *http.Request) {
+	type live struct {
+		Power   *db.PowerPoint   `json:"power"`
+		Devices []db.DevicePower `json:"devices"`
+	}
+
+	var out live
+	p, ok, err := db.LatestReading(s.db)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	if ok {
+		out.Power = &p
+	}
+	if out.Devices, err = db.LatestDevicePower(s.db); err != nil {
+		s.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handlePower(w http.ResponseWriter, r *http.Request) {
+	hours := queryInt(r, "hours", 24, 1, 24*31)
+	points, err := db.PowerSince(s.db, time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, points)
+}
+
+func (s *Server) handlePhases(w http.ResponseWriter, r *http.Request) {
+	hours := queryInt(r, "hours", 24, 1, 24*366)
+	now := time.Now()
+	out, err := db.PhaseImbalance(s.db, now.Add(-time.Duration(hours)*time.Hour), now)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleCoverage(w http.ResponseWriter, r *http.Request) {
+	days := queryInt(r, "days", 7, 1, 366)
+	from, to := health.CoverageDays(s.cfg, days, time.Now())
+	out, err := health.Coverage(s.db, s.cfg, from, to)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleDaily(w http.ResponseWriter, r *http.Request) {
+	days := queryInt(r, "days", 31, 1, 3660)
+	out, err := db.DailyConsumptions(s.db, days)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleMonthly(w http.ResponseWriter, _ *http.Request) {
+	out, err := db.MonthlyCosts(s.db)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleYearly(w http.ResponseWriter, _ *http.Request) {
+	out, err := db.CurrentYearCost(s.db, time.Now().In(s.cfg.Time.Location()))
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	log.Printf("HTTP DB-Fehler: %v", err)
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+// queryInt liest einen Integer-Parameter und begrenzt ihn auf [min, max]
+func queryInt(r *http.Request, key string, def, min, max int) int {
+	v, err := strconv.Atoi(r.URL.Query().Get(key))
+	if err != nil {
+		return def
+	}
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
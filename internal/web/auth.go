@@ -0,0 +1,82 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+type scope int
+
+const (
+	scopeNone scope = iota
+	scopeRead
+	scopeAdmin
+)
+
+func parseScope(s string) scope {
+	if strings.EqualFold(s, "admin") {
+		return scopeAdmin
+	}
+	return scopeRead
+}
+
+func authConfigured(a config.HTTPAuthConfig) bool {
+	return len(a.Tokens) > 0 || len(a.Users) > 0
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// authenticate liefert den Scope der Anfrage (Bearer-Token oder Basic-Auth)
+func (s *Server) authenticate(r *http.Request) scope {
+	auth := s.cfg.HTTP.Auth
+
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		token := strings.TrimPrefix(h, "Bearer ")
+		for _, t := range auth.Tokens {
+			if t.Token != "" && equal(token, t.Token) {
+				return parseScope(t.Scope)
+			}
+		}
+		return scopeNone
+	}
+
+	if user, pass, ok := r.BasicAuth(); ok {
+		for _, u := range auth.Users {
+			if u.Username != "" && equal(user, u.Username) && equal(pass, u.Password) {
+				return parseScope(u.Scope)
+			}
+		}
+	}
+	return scopeNone
+}
+
+// require schützt einen Handler. Ohne konfigurierte Zugangsdaten sind
+// Lese-Endpunkte offen und Admin-Endpunkte gesperrt.
+func (s *Server) require(need scope, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authConfigured(s.cfg.HTTP.Auth) {
+			if need == scopeAdmin {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "Admin-Endpunkte erfordern [http.auth]"})
+				return
+			}
+			h(w, r)
+			return
+		}
+
+		got := s.authenticate(r)
+		switch {
+		case got == scopeNone:
+			w.Header().Set("WWW-Authenticate", `Basic realm="mqttlogger"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Anmeldung erforderlich"})
+		case got < need:
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "Berechtigung fehlt"})
+		default:
+			h(w, r)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+)
+
+func request(t *testing.T, h http.Handler, method, path, body string, auth func(*http.Request)) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if auth != nil {
+		auth(req)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func bearer(token string) func(*http.Request) {
+	return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func TestWithoutAuthConfigAdminIsLocked(t *testing.T) {
+	dbh := newWebTestDB(t)
+	defer dbh.Close()
+	srv := NewServer(config.Config{}, dbh)
+
+	if rec := request(t, srv, http.MethodGet, "/api/monthly", "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("read without auth config: %d", rec.Code)
+	}
+	if rec := request(t, srv, http.MethodPost, "/api/admin/aggregate", "", nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("admin without auth config: %d", rec.Code)
+	}
+}
+
+func TestScopesAreEnforced(t *testing.T) {
+	dbh := newWebTestDB(t)
+	defer dbh.Close()
+
+	cfg := config.Config{
+		Cost: config.CostConfig{PerKWh: 0.5},
+		HTTP: config.HTTPConfig{Auth: config.HTTPAuthConfig{
+			Tokens: []config.HTTPToken{
+				{Token: "r3ad", Scope: "read"},
+				{Token: "adm1n", Scope: "admin"},
+			},
+			Users: []config.HTTPUser{{Username: "oma", Password: "geheim", Scope: "read"}},
+		}},
+	}
+	srv := NewServer(cfg, dbh)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		auth   func(*http.Request)
+		want   int
+	}{
+		{"health is public", http.MethodGet, "/healthz", "", nil, http.StatusServiceUnavailable},
+		{"spec is public", http.MethodGet, "/api/openapi.json", "", nil, http.StatusOK},
+		{"read needs login", http.MethodGet, "/api/monthly", "", nil, http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "/api/monthly", "", bearer("nope"), http.StatusUnauthorized},
+		{"read token", http.MethodGet, "/api/monthly", "", bearer("r3ad"), http.StatusOK},
+		{"basic auth", http.MethodGet, "/metrics", "", func(r *http.Request) { r.SetBasicAuth("oma", "geheim") }, http.StatusOK},
+		{"basic auth wrong password", http.MethodGet, "/", "", func(r *http.Request) { r.SetBasicAuth("oma", "x") }, http.StatusUnauthorized},
+		{"read token on admin", http.MethodPut, "/api/admin/tariff", `{"per_kwh":0.4}`, bearer("r3ad"), http.StatusForbidden},
+		{"tariff missing", http.MethodPut, "/api/admin/tariff", `{}`, bearer("adm1n"), http.StatusBadRequest},
+		{"tariff misspelled", http.MethodPut, "/api/admin/tariff", `{"per_kw":0.4}`, bearer("adm1n"), http.StatusBadRequest},
+		{"admin token", http.MethodPut, "/api/admin/tariff", `{"per_kwh":0.4}`, bearer("adm1n"), http.StatusOK},
+		{"admin can read", http.MethodGet, "/api/tariff", "", bearer("adm1n"), http.StatusOK},
+	}
+	for _, c := range cases {
+		rec := request(t, srv, c.method, c.path, c.body, c.auth)
+		if rec.Code != c.want {
+			t.Fatalf("%s: got %d, want %d (%s)", c.name, rec.Code, c.want, rec.Body.String())
+		}
+	}
+
+	if got := db.Tariff(dbh, cfg.Cost.PerKWh); got != 0.4 {
+		t.Fatalf("tariff not stored: %v", got)
+	}
+	var months []db.MonthlyCost
+	rec := request(t, srv, http.MethodGet, "/api/monthly", "", bearer("r3ad"))
+	if err := json.Unmarshal(rec.Body.Bytes(), &months); err != nil || len(months) != 1 || months[0].Cost != 4 {
+		t.Fatalf("costs not recalculated with new tariff: %v %+v", err, months)
+	}
+}
+
+func TestOpenAPISpecCoversAPIRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("invalid openapi.json: %v", err)
+	}
+	for _, p := range []string{"/api/live", "/api/daily", "/api/monthly", "/api/yearly", "/api/tariff",
+		"/api/devices", "/api/phases", "/api/coverage", "/api/admin/devices/{source}/{id}",
+		"/api/admin/tariff", "/api/admin/aggregate", "/api/admin/backup", "/healthz", "/readyz"} {
+		if _, ok := spec.Paths[p]; !ok {
+			t.Fatalf("openapi.json misses %s", p)
+		}
+	}
+}
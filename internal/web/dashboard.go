@@ -0,0 +1,30 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Das Dashboard kommt komplett aus dem Binary, ohne externe CDNs
+//
+//go:embed static
+var staticFiles embed.FS
+
+// Die API-Beschreibung liegt unter der festen URL /api/openapi.json
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func dashboardHandler() http.Handler {
+	sub, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServerFS(sub)
+}
+
+func handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
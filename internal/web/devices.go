@@ -0,0 +1,33 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/khorsmann/mqttlogger/internal/db"
+)
+
+// GET /api/devices – Geräteregister
+func (s *Server) handleDevices(w http.ResponseWriter, _ *http.Request) {
+	out, err := db.Devices(s.db)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// PUT /api/admin/devices/{source}/{id...} – Name, Raum, Kategorie, Nennleistung
+func (s *Server) handleUpdateDevice(w http.ResponseWriter, r *http.Request) {
+	var u db.DeviceUpdate
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	d, err := db.UpdateDevice(s.db, r.PathValue("source"), r.PathValue("id"), u)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
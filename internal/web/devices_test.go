@@ -0,0 +1,49 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+)
+
+func TestDeviceRegistryAPI(t *testing.T) {
+	dbh := newWebTestDB(t)
+	defer dbh.Close()
+
+	cfg := config.Config{HTTP: config.HTTPConfig{Auth: config.HTTPAuthConfig{
+		Tokens: []config.HTTPToken{{Token: "adm1n", Scope: "admin"}},
+	}}}
+	srv := NewServer(cfg, dbh)
+
+	rec := request(t, srv, http.MethodPut, "/api/admin/devices/tasmota/plug1",
+		`{"name":"Wärmepumpe","category":"heat_pump","nominal_power_w":3000}`, bearer("adm1n"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = request(t, srv, http.MethodPut, "/api/admin/devices/tasmota/plug1", `{"nominal_power_w":-1}`, bearer("adm1n"))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("negative power: %d", rec.Code)
+	}
+
+	// Zigbee-Namen dürfen "/" enthalten
+	rec = request(t, srv, http.MethodPut, "/api/admin/devices/zigbee/Keller/Gefriertruhe", `{"room":"Keller"}`, bearer("adm1n"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update id with slash: %d %s", rec.Code, rec.Body.String())
+	}
+
+	var devices []db.Device
+	rec = request(t, srv, http.MethodGet, "/api/devices", "", bearer("adm1n"))
+	if err := json.Unmarshal(rec.Body.Bytes(), &devices); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rec.Body.String())
+	}
+	if len(devices) != 2 || devices[0].Name != "Wärmepumpe" || devices[0].Category != "heat_pump" || *devices[0].NominalPower != 3000 {
+		t.Fatalf("unexpected devices: %s", rec.Body.String())
+	}
+	if devices[1].Source != "zigbee" || devices[1].ID != "Keller/Gefriertruhe" || devices[1].Room != "Keller" {
+		t.Fatalf("unexpected zigbee device: %+v", devices[1])
+	}
+}
@@ -0,0 +1,163 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/db"
+)
+
+// -------------------------------------------------------------------
+// Grafana JSON-Datasource (simpod-json-datasource, auch für Infinity)
+// Basis-URL in Grafana: http://<host>/grafana
+// -------------------------------------------------------------------
+
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs    int64 `json:"intervalMs"`
+	MaxDataPoints int64 `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Type   string `json:"type"`
+	} `json:"targets"`
+}
+
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+type grafanaTable struct {
+	Type string `json:"type"`
+	db.Table
+}
+
+// GET /grafana/ – Verbindungstest
+func (s *Server) handleGrafanaTest(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK"))
+}
+
+// POST /grafana/search – alle Zeitreihen und Tabellen
+func (s *Server) handleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target string `json:"target"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	names, err := s.grafanaTargets()
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	out := []string{}
+	for _, n := range names {
+		if strings.Contains(n, req.Target) {
+			out = append(out, n)
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// POST /grafana/metrics – Variante der neueren Plugin-Versionen
+func (s *Server) handleGrafanaMetrics(w http.ResponseWriter, _ *http.Request) {
+	names, err := s.grafanaTargets()
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	type option struct {
+		Label string `json:"label"`
+		Value string `json:"value"`
+	}
+	out := make([]option, len(names))
+	for i, n := range names {
+		out[i] = option{Label: n, Value: n}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// POST /grafana/query – Zeitreihen (verdichtet) bzw. Tabellen
+func (s *Server) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var q grafanaQuery
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	to := q.Range.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := q.Range.From
+	if from.IsZero() {
+		from = to.Add(-24 * time.Hour)
+	}
+	step := grafanaStep(from, to, q.IntervalMs, q.MaxDataPoints)
+
+	out := []any{}
+	for _, t := range q.Targets {
+		if t.Target == "" {
+			continue
+		}
+
+		if t.Type == "table" || slices.Contains(db.TableNames(), t.Target) {
+			tbl, err := db.QueryTable(s.db, t.Target, time.Now().In(s.cfg.Time.Location()))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			out = append(out, grafanaTable{Type: "table", Table: tbl})
+			continue
+		}
+
+		points, err := db.QuerySeries(s.db, t.Target, from, to, step, s.cfg.Time.Location())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		series := grafanaSeries{Target: t.Target, Datapoints: make([][2]float64, len(points))}
+		for i, p := range points {
+			series.Datapoints[i] = [2]float64{p.Value, float64(p.Time.UnixMilli())}
+		}
+		out = append(out, series)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// POST /grafana/annotations – keine Annotationen
+func (s *Server) handleGrafanaAnnotations(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, []any{})
+}
+
+func (s *Server) grafanaTargets() ([]string, error) {
+	names, err := db.SeriesNames(s.db)
+	if err != nil {
+		return nil, err
+	}
+	return append(names, db.TableNames()...), nil
+}
+
+// grafanaStep bestimmt das Verdichtungsintervall: mindestens intervalMs,
+// aber nicht mehr Punkte als maxDataPoints
+func grafanaStep(from, to time.Time, intervalMs, maxDataPoints int64) time.Duration {
+	step := time.Duration(intervalMs) * time.Millisecond
+	if maxDataPoints > 0 {
+		if minStep := to.Sub(from) / time.Duration(maxDataPoints); minStep > step {
+			step = minStep
+		}
+	}
+	if step < time.Second {
+		step = time.Second
+	}
+	return step
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+)
+
+func post(t *testing.T, h http.Handler, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestGrafanaSearchListsSeriesAndTables(t *testing.T) {
+	dbh := newWebTestDB(t)
+	defer dbh.Close()
+	if _, err := dbh.Exec(`INSERT INTO tasmota_data (device_id, timestamp_unix, timestamp_rfc3339, power) VALUES ('plug1', 1, '', 5)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := dbh.Exec(`INSERT INTO shelly_data (device_id, channel, timestamp_unix, power) VALUES ('pro3em', 2, 1, 5)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	srv := NewServer(config.Config{}, dbh)
+
+	if rec := get(t, srv, "/grafana/"); rec.Code != http.StatusOK {
+		t.Fatalf("test endpoint: %d", rec.Code)
+	}
+
+	var names []string
+	rec := post(t, srv, "/grafana/search", `{"target":""}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &names); err != nil {
+		t.Fatalf("decode search: %v", err)
+	}
+	for _, want := range []string{"power", "e_in", "tasmota/plug1/power", "shelly/pro3em/2/power", "monthly_energy_cost"} {
+		found := false
+		for _, n := range names {
+			found = found || n == want
+		}
+		if !found {
+			t.Fatalf("search result misses %q: %v", want, names)
+		}
+	}
+}
+
+func TestGrafanaQueryDownsamplesAndReturnsTables(t *testing.T) {
+	dbh := newWebTestDB(t)
+	defer dbh.Close()
+	srv := NewServer(config.Config{}, dbh)
+
+	// Drei Tageswerte (250, 251, 252 W) in einem 7-Tage-Intervall -> ein Punkt mit Mittelwert
+	body := `{
+		"range": {"from": "2025-10-30T00:00:00Z", "to": "2025-11-06T00:00:00Z"},
+		"intervalMs": 604800000,
+		"maxDataPoints": 100,
+		"targets": [
+			{"target": "power", "refId": "A", "type": "timeserie"},
+			{"target": "monthly_energy_cost", "refId": "B", "type": "table"}
+		]
+	}`
+	rec := post(t, srv, "/grafana/query", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("query: %d %s", rec.Code, rec.Body.String())
+	}
+
+	var out []json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || len(out) != 2 {
+		t.Fatalf("decode query: %v (%s)", err, rec.Body.String())
+	}
+
+	var series grafanaSeries
+	if err := json.Unmarshal(out[0], &series); err != nil {
+		t.Fatalf("decode series: %v", err)
+	}
+	if len(series.Datapoints) != 1 || series.Datapoints[0][0] != 251 {
+		t.Fatalf("unexpected datapoints: %v", series.Datapoints)
+	}
+
+	var table grafanaTable
+	if err := json.Unmarshal(out[1], &table); err != nil {
+		t.Fatalf("decode table: %v", err)
+	}
+	if table.Type != "table" || len(table.Columns) != 3 || len(table.Rows) != 1 || table.Rows[0][0] != "2025-11" {
+		t.Fatalf("unexpected table: %+v", table)
+	}
+}
+
+func TestGrafanaStepRespectsMaxDataPoints(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(100 * time.Hour)
+
+	if got := grafanaStep(from, to, 60000, 10); got != 10*time.Hour {
+		t.Fatalf("expected 10h, got %s", got)
+	}
+	if got := grafanaStep(from, to, 3600000, 1000); got != time.Hour {
+		t.Fatalf("expected 1h, got %s", got)
+	}
+}
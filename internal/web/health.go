@@ -0,0 +1,27 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/khorsmann/mqttlogger/internal/health"
+)
+
+// /healthz schlägt nur bei kritischen Fehlern fehl (Prozess, MQTT, DB)
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	r := s.checker.Liveness()
+	code := http.StatusOK
+	if r.Status == health.Critical {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, r)
+}
+
+// /readyz ist nur ok, wenn zusätzlich alle Quellen aktuelle Daten liefern
+func (s *Server) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	r := s.checker.Readiness()
+	code := http.StatusOK
+	if r.Status != health.OK {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, r)
+}
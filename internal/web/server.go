@@ -0,0 +1,98 @@
+package web
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/health"
+	"github.com/khorsmann/mqttlogger/internal/metrics"
+	"github.com/khorsmann/mqttlogger/internal/stream"
+)
+
+// Server bündelt die HTTP-Endpunkte des Loggers
+type Server struct {
+	cfg     config.Config
+	db      *sql.DB
+	mux     *http.ServeMux
+	checker *health.Checker
+	hub     *stream.Hub
+}
+
+// NewServer erstellt den Server und registriert die Routen
+func NewServer(cfg config.Config, db *sql.DB) *Server {
+	s := &Server{
+		cfg:     cfg,
+		db:      db,
+		mux:     http.NewServeMux(),
+		checker: health.NewChecker(cfg, db, health.Default),
+		hub:     stream.Default,
+	}
+	s.routes()
+	return s
+}
+
+func (s *Server) routes() {
+	// ohne Anmeldung
+	s.mux.HandleFunc("GET /healthz", s.handleHealthz)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+	s.mux.HandleFunc("GET /api/openapi.json", handleOpenAPI)
+
+	// Scope read
+	read := func(pattern string, h http.HandlerFunc) {
+		s.mux.HandleFunc(pattern, s.require(scopeRead, h))
+	}
+	read("GET /metrics", metrics.Default.Handler().ServeHTTP)
+
+	read("GET /api/live", s.handleLive)
+	read("GET /api/power", s.handlePower)
+	read("GET /api/phases", s.handlePhases)
+	read("GET /api/coverage", s.handleCoverage)
+	read("GET /api/daily", s.handleDaily)
+	read("GET /api/monthly", s.handleMonthly)
+	read("GET /api/yearly", s.handleYearly)
+	read("GET /api/stream", s.handleStream)
+	read("GET /api/tariff", s.handleGetTariff)
+	read("GET /api/devices", s.handleDevices)
+
+	read("GET /grafana/{$}", s.handleGrafanaTest)
+	read("POST /grafana/search", s.handleGrafanaSearch)
+	read("POST /grafana/metrics", s.handleGrafanaMetrics)
+	read("POST /grafana/query", s.handleGrafanaQuery)
+	read("POST /grafana/annotations", s.handleGrafanaAnnotations)
+
+	read("GET /", dashboardHandler().ServeHTTP)
+
+	// Scope admin
+	admin := func(pattern string, h http.HandlerFunc) {
+		s.mux.HandleFunc(pattern, s.require(scopeAdmin, h))
+	}
+	admin("POST /api/admin/backup", s.handleBackup)
+	admin("POST /api/admin/aggregate", s.handleAggregate)
+	admin("PUT /api/admin/tariff", s.handleSetTariff)
+	admin("PUT /api/admin/devices/{source}/{id...}", s.handleUpdateDevice)
+}
+
+// ServeHTTP erlaubt den Einsatz als http.Handler (z.B. in Tests)
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Start startet den HTTP-Server im Hintergrund, falls [http] listen gesetzt ist
+func Start(cfg config.Config, db *sql.DB) {
+	if cfg.HTTP.Listen == "" {
+		return
+	}
+
+	srv := NewServer(cfg, db)
+	if !authConfigured(cfg.HTTP.Auth) {
+		log.Printf("HTTP ohne [http.auth]: Daten sind frei lesbar, Admin-Endpunkte gesperrt")
+	}
+	go func() {
+		log.Printf("HTTP-Server lauscht auf %s", cfg.HTTP.Listen)
+		if err := http.ListenAndServe(cfg.HTTP.Listen, srv); err != nil {
+			log.Printf("HTTP-Server beendet: %v", err)
+		}
+	}()
+}
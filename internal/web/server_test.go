@@ -0,0 +1,142 @@
+package web
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/config"
+	"github.com/khorsmann/mqttlogger/internal/db"
+	"github.com/khorsmann/mqttlogger/internal/stream"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// helper: in-memory DB mit vollständigem Schema und ein paar Messwerten
+func newWebTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dbh, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	dbh.SetMaxOpenConns(1)
+
+	// CreateSchema statt InitDB: der Aggregations-Loop würde parallel laufen
+	cfg := config.Config{Cost: config.CostConfig{PerKWh: 0.5}}
+	if err := db.CreateSchema(dbh); err != nil {
+		t.Fatalf("CreateSchema: %v", err)
+	}
+
+	insert := `INSERT INTO energy_data (timestamp_unix, timestamp_rfc3339, e_in, e_out, power) VALUES (?, ?, ?, 0, ?)`
+	for i, eIn := range []float64{100, 104, 110} {
+		ts := time.Date(2025, 11, 1+i, 12, 0, 0, 0, time.UTC)
+		if _, err := dbh.Exec(insert, ts.Unix(), ts.Format(time.RFC3339), eIn, 250+i); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	db.RunAggregations(dbh, cfg)
+	return dbh
+}
+
+func get(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestDashboardIsEmbedded(t *testing.T) {
+	dbh := newWebTestDB(t)
+	defer dbh.Close()
+	srv := NewServer(config.Config{}, dbh)
+
+	rec := get(t, srv, "/")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "app.js") {
+		t.Fatalf("unexpected index: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := get(t, srv, "/app.js"); rec.Code != http.StatusOK {
+		t.Fatalf("app.js: %d", rec.Code)
+	}
+}
+
+func TestAPIReadsViews(t *testing.T) {
+	dbh := newWebTestDB(t)
+	defer dbh.Close()
+	srv := NewServer(config.Config{}, dbh)
+
+	var months []db.MonthlyCost
+	rec := get(t, srv, "/api/monthly")
+	if err := json.Unmarshal(rec.Body.Bytes(), &months); err != nil {
+		t.Fatalf("decode monthly: %v (%s)", err, rec.Body.String())
+	}
+	if len(months) != 1 || months[0].Month != "2025-11" || months[0].Consumption != 10 || months[0].Cost != 5 {
+		t.Fatalf("unexpected monthly: %+v", months)
+	}
+
+	var days []db.DailyConsumption
+	rec = get(t, srv, "/api/daily?days=2")
+	if err := json.Unmarshal(rec.Body.Bytes(), &days); err != nil {
+		t.Fatalf("decode daily: %v", err)
+	}
+	if len(days) != 2 || days[1].Day != "2025-11-03" {
+		t.Fatalf("unexpected daily: %+v", days)
+	}
+
+	var live struct {
+		Power *db.PowerPoint `json:"power"`
+	}
+	rec = get(t, srv, "/api/live")
+	if err := json.Unmarshal(rec.Body.Bytes(), &live); err != nil {
+		t.Fatalf("decode live: %v", err)
+	}
+	if live.Power == nil || live.Power.Power != 252 {
+		t.Fatalf("unexpected live: %s", rec.Body.String())
+	}
+}
+
+func TestStreamDeliversFilteredReadings(t *testing.T) {
+	dbh := newWebTestDB(t)
+	defer dbh.Close()
+	srv := NewServer(config.Config{}, dbh)
+	srv.hub = stream.NewHub()
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/stream?device=plug1")
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	// warten bis der Handler abonniert hat
+	for i := 0; srv.hub.Subscribers() == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	srv.hub.Publish(stream.Reading{Source: "tasmota", Device: "plug2", Metric: "power", Value: 1})
+	srv.hub.Publish(stream.Reading{Source: "tasmota", Device: "plug1", Metric: "power", Value: 42})
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var r stream.Reading
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &r); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		if r.Device != "plug1" || r.Value != 42 {
+			t.Fatalf("unexpected reading: %+v", r)
+		}
+		return
+	}
+	t.Fatalf("stream ended without reading: %v", scanner.Err())
+}
@@ -0,0 +1,177 @@
+// mqttlogger Dashboard – bewusst ohne externe Bibliotheken
+
+"use strict";
+
+const fmt = (v, d) => Number(v).toLocaleString("de-DE", { minimumFractionDigits: d, maximumFractionDigits: d });
+
+async function getJSON(url) {
+	const res = await fetch(url);
+	if (!res.ok) {
+		throw new Error(url + ": " + res.status);
+	}
+	return res.json();
+}
+
+// -------------------------------------------------------------------
+// Minimaler Canvas-Chart (Linie oder Balken)
+// -------------------------------------------------------------------
+
+function drawChart(canvas, labels, values, opts) {
+	const ratio = window.devicePixelRatio || 1;
+	const width = canvas.clientWidth;
+	const height = canvas.clientHeight || Number(canvas.getAttribute("height"));
+	canvas.width = width * ratio;
+	canvas.height = height * ratio;
+
+	const ctx = canvas.getContext("2d");
+	ctx.scale(ratio, ratio);
+	ctx.clearRect(0, 0, width, height);
+
+	const style = getComputedStyle(document.documentElement);
+	const fg = style.getPropertyValue("--muted").trim();
+	const color = style.getPropertyValue(opts.color || "--accent").trim();
+
+	const pad = { left: 48, right: 8, top: 8, bottom: 22 };
+	const w = width - pad.left - pad.right;
+	const h = height - pad.top - pad.bottom;
+
+	if (values.length === 0) {
+		ctx.fillStyle = fg;
+		ctx.fillText("keine Daten", pad.left, pad.top + 12);
+		return;
+	}
+
+	const max = Math.max(...values, 0) * 1.1 || 1;
+	const y = (v) => pad.top + h - (v / max) * h;
+
+	// Achsen & Hilfslinien
+	ctx.strokeStyle = fg;
+	ctx.fillStyle = fg;
+	ctx.globalAlpha = 0.3;
+	ctx.font = "11px system-ui, sans-serif";
+	for (let i = 0; i <= 4; i++) {
+		const v = (max / 4) * i;
+		ctx.beginPath();
+		ctx.moveTo(pad.left, y(v));
+		ctx.lineTo(pad.left + w, y(v));
+		ctx.stroke();
+	}
+	ctx.globalAlpha = 1;
+	for (let i = 0; i <= 4; i++) {
+		const v = (max / 4) * i;
+		ctx.fillText(fmt(v, opts.digits || 0), 2, y(v) + 4);
+	}
+
+	// Beschriftung x-Achse (max. 8 Labels)
+	const step = Math.max(1, Math.ceil(labels.length / 8));
+	for (let i = 0; i < labels.length; i += step) {
+		const x = pad.left + (w * (i + 0.5)) / labels.length;
+		ctx.fillText(labels[i], x - 16, height - 6);
+	}
+
+	ctx.fillStyle = color;
+	ctx.strokeStyle = color;
+
+	if (opts.type === "bar") {
+		const bw = (w / values.length) * 0.8;
+		values.forEach((v, i) => {
+			const x = pad.left + (w * i) / values.length + bw * 0.125;
+			ctx.fillRect(x, y(v), bw, pad.top + h - y(v));
+		});
+		return;
+	}
+
+	ctx.lineWidth = 1.5;
+	ctx.beginPath();
+	values.forEach((v, i) => {
+		const x = pad.left + (w * i) / Math.max(1, values.length - 1);
+		if (i === 0) {
+			ctx.moveTo(x, y(v));
+		} else {
+			ctx.lineTo(x, y(v));
+		}
+	});
+	ctx.stroke();
+}
+
+// -------------------------------------------------------------------
+// Daten laden
+// -------------------------------------------------------------------
+
+async function loadLive() {
+	const live = await getJSON("api/live");
+	if (live.power) {
+		document.getElementById("live-power").textContent = fmt(live.power.power, 0);
+		document.getElementById("live-time").textContent = new Date(live.power.time).toLocaleString("de-DE");
+	}
+
+	const tbody = document.querySelector("#devices tbody");
+	tbody.replaceChildren(...live.devices.map((d) => {
+		const tr = document.createElement("tr");
+		tr.innerHTML = "<td></td><td></td>";
+		tr.children[0].textContent = d.name || d.device;
+		tr.children[1].textContent = fmt(d.power, 0) + " W";
+		return tr;
+	}));
+}
+
+async function loadPower() {
+	const points = await getJSON("api/power?hours=24");
+	drawChart(
+		document.getElementById("power-chart"),
+		points.map((p) => new Date(p.time).toLocaleTimeString("de-DE", { hour: "2-digit", minute: "2-digit" })),
+		points.map((p) => p.power),
+		{ type: "line" },
+	);
+}
+
+async function loadDaily() {
+	const days = await getJSON("api/daily?days=31");
+	drawChart(
+		document.getElementById("daily-chart"),
+		days.map((d) => d.day.slice(5)),
+		days.map((d) => d.consumption),
+		{ type: "bar", color: "--accent2", digits: 1 },
+	);
+}
+
+async function loadCosts() {
+	const [months, year] = await Promise.all([getJSON("api/monthly"), getJSON("api/yearly")]);
+
+	document.getElementById("year-kwh").textContent = fmt(year.consumption, 1);
+	document.getElementById("year-cost").textContent = fmt(year.cost, 2);
+
+	const tbody = document.querySelector("#monthly tbody");
+	tbody.replaceChildren(...months.slice().reverse().map((m) => {
+		const tr = document.createElement("tr");
+		tr.innerHTML = "<td></td><td></td><td></td>";
+		tr.children[0].textContent = m.month;
+		tr.children[1].textContent = fmt(m.consumption, 1);
+		tr.children[2].textContent = fmt(m.cost, 2);
+		return tr;
+	}));
+}
+
+async function refresh(loaders) {
+	try {
+		await Promise.all(loaders.map((l) => l()));
+		document.getElementById("updated").textContent = "Stand: " + new Date().toLocaleTimeString("de-DE");
+	} catch (err) {
+		document.getElementById("updated").textContent = "Fehler: " + err.message;
+	}
+}
+
+// Live-Leistung per Server-Sent Events, Polling bleibt als Fallback
+if (window.EventSource) {
+	const es = new EventSource("api/stream?source=wattwaechter&metric=power");
+	es.addEventListener("reading", (ev) => {
+		const r = JSON.parse(ev.data);
+		document.getElementById("live-power").textContent = fmt(r.value, 0);
+		document.getElementById("live-time").textContent = new Date(r.time).toLocaleString("de-DE");
+	});
+}
+
+refresh([loadLive, loadPower, loadDaily, loadCosts]);
+setInterval(() => refresh([loadLive, loadPower]), 10000);
+setInterval(() => refresh([loadDaily, loadCosts]), 600000);
+window.addEventListener("resize", () => refresh([loadPower, loadDaily]));
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/khorsmann/mqttlogger/internal/stream"
+)
+
+// handleStream liefert alle neuen Messwerte als Server-Sent Events.
+// Filter: ?source=tasmota&device=plug1,plug2&metric=power
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming nicht unterstützt", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	sub := s.hub.Subscribe(stream.Filter{
+		Sources: stream.ParseList(q.Get("source")),
+		Devices: stream.ParseList(q.Get("device")),
+		Metrics: stream.ParseList(q.Get("metric")),
+	}, 64)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": verbunden\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case reading, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(reading)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: reading\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}